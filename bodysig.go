@@ -0,0 +1,159 @@
+package traefik_jwt_middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwk"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jws"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwt"
+)
+
+// Middleware modes
+const (
+	// modeJWT verifies a JWT carried in the AuthHeader
+	modeJWT = "jwt"
+	// modeBodySignature verifies a detached JWS carried in the
+	// SignatureHeader, computed over the raw request body
+	modeBodySignature = "bodySignature"
+)
+
+const (
+	defaultSignatureHeader = "X-JWS-Signature"
+	defaultMaxBodySize     = 1 << 20
+)
+
+var errBodyTooLarge = errors.New("request body exceeds the maximum size")
+
+// serveBodySignature Verifies the detached JWS in the signature header over
+// the request body, and forwards the request with its body restored
+func (j *JWT) serveBodySignature(res http.ResponseWriter, req *http.Request) {
+	signature := strings.TrimSpace(req.Header.Get(j.signatureHeader))
+	if len(signature) == 0 {
+		if j.optionalAuth || !j.deny(res, req, reasonMissing, http.StatusBadRequest, "") {
+			j.forwardAnonymous(res, req)
+		}
+		return
+	}
+
+	// the body cannot be forwarded once it failed to be read, so such
+	// requests are rejected even if malformed requests are only reported
+	body, err := readBody(req, j.maxBodySize)
+	if err != nil {
+		j.metrics.Deny(reasonMalformed)
+		if errors.Is(err, errBodyTooLarge) {
+			http.Error(res, "Request entity too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(res, "Request error", http.StatusBadRequest)
+		return
+	}
+
+	start := time.Now()
+	verificationError := j.verifyBodySignature(req.Context(), body, signature)
+	j.metrics.ObserveVerification(time.Since(start))
+	if verificationError != nil {
+		if !j.deny(res, req, denyReason(verificationError, signature), http.StatusUnauthorized, "") {
+			j.forwardAnonymous(res, req)
+		}
+		return
+	}
+
+	// no token is injected for signed bodies, so none may be passed on
+	req.Header.Del(j.proxyHeaderName)
+	j.forward(res, req)
+}
+
+// readBody Reads the request body up to the given size, and replaces it
+// with a copy so that it can be read again by the next handler
+func readBody(req *http.Request, maxSize int64) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.ContentLength > maxSize {
+		return nil, errBodyTooLarge
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxSize+1))
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if int64(len(body)) > maxSize {
+		return nil, errBodyTooLarge
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	return body, nil
+}
+
+// verifyBodySignature Verifies the detached JWS over the body with the key
+// set, or with the secret if there is none. Like for tokens, the key set is
+// re-fetched once if the signature refers to an unknown key ID.
+func (j *JWT) verifyBodySignature(ctx context.Context, body []byte, signature string) error {
+	var keySet *jwk.Set
+	var err error
+	if j.keys != nil {
+		keySet, err = j.keys.KeySet(ctx)
+	} else {
+		keySet, err = jwk.ParseString(j.secret)
+	}
+	if err != nil {
+		return err
+	}
+
+	msg, err := jws.ParseString(signature)
+	if err != nil {
+		return err
+	}
+	if len(msg.Signatures()) != 1 {
+		return errors.New("expected exactly one signature")
+	}
+	hdrs := msg.Signatures()[0].ProtectedHeaders()
+
+	err = verifyDetachedWithKeySet(body, signature, hdrs, keySet, j.parseOptions...)
+	var unknownKeyID *jwt.UnknownKeyIDError
+	if err == nil || !errors.As(err, &unknownKeyID) {
+		return err
+	}
+
+	r, ok := j.keys.(refresher)
+	if !ok {
+		return err
+	}
+	keySet, refreshErr := r.Refresh(ctx)
+	if refreshErr != nil {
+		return err
+	}
+	return verifyDetachedWithKeySet(body, signature, hdrs, keySet, j.parseOptions...)
+}
+
+// verifyDetachedWithKeySet Verifies the detached JWS over the body with the
+// keys of the key set that a token with the same headers would be verified
+// with
+func verifyDetachedWithKeySet(body []byte, signature string, hdrs jws.Headers, keySet *jwk.Set, options ...jwt.Option) error {
+	options = append([]jwt.Option{jwt.UseDefaultKey(true)}, options...)
+	alg, keys, err := jwt.LookupKeys(hdrs, keySet, options...)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		var raw interface{}
+		if err := key.Raw(&raw); err != nil {
+			continue
+		}
+		if err := jws.VerifyDetached(body, []byte(signature), alg, raw); err == nil {
+			return nil
+		}
+	}
+	return errors.New("failed to verify body signature with any of the keys")
+}
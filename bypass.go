@@ -0,0 +1,146 @@
+package traefik_jwt_middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// BypassRule describes requests that are forwarded without authentication.
+// A request matches the rule if it matches all of its conditions. Host may
+// start with "*." to match any subdomain.
+type BypassRule struct {
+	PathPrefix string   `json:"pathPrefix,omitempty"`
+	PathRegex  string   `json:"pathRegex,omitempty"`
+	Methods    []string `json:"methods,omitempty"`
+	Host       string   `json:"host,omitempty"`
+}
+
+// bypassRule is a compiled BypassRule
+type bypassRule struct {
+	pathPrefix string
+	pathRegex  *regexp.Regexp
+	methods    map[string]bool
+	host       string
+}
+
+// compileBypassRules Compiles the rules, rejecting rules without conditions
+// which would bypass authentication for every request
+func compileBypassRules(rules []BypassRule) ([]bypassRule, error) {
+	compiled := make([]bypassRule, 0, len(rules))
+	for i, rule := range rules {
+		if len(rule.PathPrefix) == 0 && len(rule.PathRegex) == 0 && len(rule.Methods) == 0 && len(rule.Host) == 0 {
+			return nil, fmt.Errorf("bypass rule #%d has no conditions", i+1)
+		}
+
+		c := bypassRule{
+			pathPrefix: rule.PathPrefix,
+			host:       strings.ToLower(rule.Host),
+		}
+		if len(rule.PathRegex) > 0 {
+			re, err := regexp.Compile(rule.PathRegex)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compile bypass rule #%d: %w", i+1, err)
+			}
+			c.pathRegex = re
+		}
+		if len(rule.Methods) > 0 {
+			c.methods = make(map[string]bool, len(rule.Methods))
+			for _, method := range rule.Methods {
+				c.methods[strings.ToUpper(method)] = true
+			}
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+func (r *bypassRule) matches(req *http.Request, cleanPath string) bool {
+	if len(r.pathPrefix) > 0 && !strings.HasPrefix(cleanPath, r.pathPrefix) {
+		return false
+	}
+	if r.pathRegex != nil && !r.pathRegex.MatchString(cleanPath) {
+		return false
+	}
+	if r.methods != nil && !r.methods[req.Method] {
+		return false
+	}
+	if len(r.host) > 0 && !matchHost(r.host, req.Host) {
+		return false
+	}
+	return true
+}
+
+// matchHost Reports whether the host of the request, without its port,
+// matches the pattern
+func matchHost(pattern, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return host == pattern
+}
+
+// isPreflight Reports whether the request is a CORS preflight request
+func isPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions &&
+		len(req.Header.Get("Origin")) > 0 &&
+		len(req.Header.Get("Access-Control-Request-Method")) > 0
+}
+
+// bypassPath Returns the cleaned path of the request that bypass rules are
+// matched against, and whether it may be bypassed at all. Paths with dot
+// segments or encoded slashes are never bypassed, since upstream services may
+// resolve them to a different path than the one matched.
+func bypassPath(req *http.Request) (string, bool) {
+	escaped := strings.ToLower(req.URL.EscapedPath())
+	if strings.Contains(escaped, "%2f") || strings.Contains(escaped, "%5c") {
+		return "", false
+	}
+	for _, segment := range strings.Split(req.URL.Path, "/") {
+		if segment == "." || segment == ".." {
+			return "", false
+		}
+	}
+
+	cleaned := path.Clean("/" + req.URL.Path)
+	if strings.HasSuffix(req.URL.Path, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned, true
+}
+
+// bypass Reports whether the request is forwarded without authentication
+func (j *JWT) bypass(req *http.Request) bool {
+	if j.bypassPreflight && isPreflight(req) {
+		return true
+	}
+	if len(j.bypassRules) == 0 {
+		return false
+	}
+
+	cleanPath, ok := bypassPath(req)
+	if !ok {
+		return false
+	}
+	for i := range j.bypassRules {
+		if j.bypassRules[i].matches(req, cleanPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardAnonymous Forwards the request without authentication, making sure
+// the caller cannot inject the header holding the verified token
+func (j *JWT) forwardAnonymous(res http.ResponseWriter, req *http.Request) {
+	req.Header.Del(j.proxyHeaderName)
+	j.forward(res, req)
+}
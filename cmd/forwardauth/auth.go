@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	traefik_jwt_middleware "github.com/whlanuo/traefik-jwt-middleware"
+)
+
+// wouldDenyHeader is set by the middleware in report mode, and passed on in
+// the response so that it can be listed in authResponseHeaders
+const wouldDenyHeader = "X-Auth-Would-Deny"
+
+// authHandler answers Traefik's ForwardAuth requests: the original request
+// is reconstructed from the X-Forwarded-* headers and verified by the
+// middleware. Allowed requests are answered with 200 and the claim headers,
+// others with the status of the middleware, 400 being turned into 401.
+//
+// The X-Forwarded-* headers are trusted, so the server must only be
+// reachable by Traefik.
+type authHandler struct {
+	middleware      *traefik_jwt_middleware.JWT
+	proxyHeaderName string
+	claimHeaders    map[string]string
+}
+
+// newAuthHandler Creates the handler for the given configuration. If prev
+// is not nil, the middleware is reloaded from it, keeping its replay caches
+// and metrics.
+func newAuthHandler(c *config, prev *authHandler) (*authHandler, error) {
+	h := &authHandler{claimHeaders: c.ClaimHeaders}
+
+	// the metrics and JWKS are served by the server itself, and must not be
+	// mistaken for authenticated requests to the same path
+	middlewareConfig := c.Config
+	middlewareConfig.MetricsPath = ""
+	middlewareConfig.InternalJwksPath = ""
+
+	var middleware *traefik_jwt_middleware.JWT
+	var err error
+	if prev != nil {
+		middleware, err = prev.middleware.Reload(http.HandlerFunc(h.allow), &middlewareConfig)
+	} else {
+		middleware, err = traefik_jwt_middleware.NewHandler(http.HandlerFunc(h.allow), &middlewareConfig, "forwardauth")
+	}
+	if err != nil {
+		return nil, err
+	}
+	h.middleware = middleware
+	h.proxyHeaderName = middlewareConfig.ProxyHeaderName
+	return h, nil
+}
+
+func (h *authHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	forwarded, err := forwardedRequest(req)
+	if err != nil {
+		http.Error(res, "Request error", http.StatusBadRequest)
+		return
+	}
+	// only the token injected by the middleware may be passed on
+	forwarded.Header.Del(h.proxyHeaderName)
+	h.middleware.ServeHTTP(&authResponseWriter{ResponseWriter: res}, forwarded)
+}
+
+// allow Answers allowed requests with the token injected by the middleware,
+// the claims of the verified token, and the reasons it would have been
+// denied for in report mode
+func (h *authHandler) allow(res http.ResponseWriter, req *http.Request) {
+	if token := req.Header.Get(h.proxyHeaderName); len(token) > 0 {
+		res.Header().Set(h.proxyHeaderName, token)
+	}
+	for _, reason := range req.Header.Values(wouldDenyHeader) {
+		res.Header().Add(wouldDenyHeader, reason)
+	}
+
+	if tk, ok := traefik_jwt_middleware.TokenFromContext(req.Context()); ok {
+		for header, claim := range h.claimHeaders {
+			if v, ok := tk.Get(claim); ok {
+				res.Header().Set(header, claimValue(v))
+			}
+		}
+	}
+	res.WriteHeader(http.StatusOK)
+}
+
+// forwardedRequest Returns the original request described by the
+// X-Forwarded-Method, X-Forwarded-Proto, X-Forwarded-Host and
+// X-Forwarded-Uri headers set by Traefik
+func forwardedRequest(req *http.Request) (*http.Request, error) {
+	uri := req.Header.Get("X-Forwarded-Uri")
+	if len(uri) == 0 {
+		uri = "/"
+	}
+	u, err := url.ParseRequestURI(uri)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse forwarded URI: %w", err)
+	}
+
+	u.Scheme = strings.ToLower(req.Header.Get("X-Forwarded-Proto"))
+	if len(u.Scheme) == 0 {
+		u.Scheme = "http"
+	}
+	u.Host = req.Header.Get("X-Forwarded-Host")
+	if len(u.Host) == 0 {
+		u.Host = req.Host
+	}
+
+	forwarded := req.Clone(req.Context())
+	if method := req.Header.Get("X-Forwarded-Method"); len(method) > 0 {
+		forwarded.Method = method
+	}
+	forwarded.URL = u
+	forwarded.Host = u.Host
+	forwarded.RequestURI = uri
+	return forwarded, nil
+}
+
+// claimValue Formats a claim as a header value: strings as is, dates as
+// seconds since the epoch, lists of strings comma separated, and other
+// values JSON encoded
+func claimValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case time.Time:
+		return strconv.FormatInt(v.Unix(), 10)
+	case []string:
+		return strings.Join(v, ",")
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(encoded)
+}
+
+// authResponseWriter answers requests the middleware rejects as bad
+// requests, because they carry no token or a malformed one, with 401 as
+// expected by ForwardAuth
+type authResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *authResponseWriter) WriteHeader(status int) {
+	if status == http.StatusBadRequest {
+		if len(w.Header().Get("WWW-Authenticate")) == 0 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		status = http.StatusUnauthorized
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
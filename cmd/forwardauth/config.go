@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	traefik_jwt_middleware "github.com/whlanuo/traefik-jwt-middleware"
+)
+
+const (
+	defaultListen          = ":8080"
+	defaultShutdownTimeout = 10 * time.Second
+
+	// envPrefix is the prefix of environment variables overriding the
+	// configuration, such as FORWARDAUTH_JWKS_URL for jwksURL
+	envPrefix = "FORWARDAUTH_"
+)
+
+// defaultClaimHeaders are the response headers set from the claims of
+// verified tokens when none are configured
+var defaultClaimHeaders = map[string]string{"X-Auth-Subject": "sub"}
+
+// config is the configuration of the server: the middleware configuration,
+// and the settings of the server itself
+type config struct {
+	traefik_jwt_middleware.Config
+	// Listen is the address the server listens on (default ":8080")
+	Listen string `json:"listen,omitempty"`
+	// ClaimHeaders maps response headers to the claims they are set from,
+	// for Traefik's authResponseHeaders (default X-Auth-Subject from "sub")
+	ClaimHeaders map[string]string `json:"claimHeaders,omitempty"`
+	// ShutdownTimeout bounds the time to wait for requests in flight on
+	// shutdown (default 10s)
+	ShutdownTimeout string `json:"shutdownTimeout,omitempty"`
+
+	shutdownTimeout time.Duration
+}
+
+// loadConfig Loads the configuration from the given file if any, JSON if its
+// extension is .json and YAML otherwise, then overrides it with the
+// environment
+func loadConfig(path string, environ []string) (*config, error) {
+	c := &config{}
+	if len(path) > 0 {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		}
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			err = json.Unmarshal(data, c)
+		} else {
+			err = decodeYAML(data, c)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+	}
+
+	if err := decodeEnv(environ, c); err != nil {
+		return nil, err
+	}
+
+	if len(c.Listen) == 0 {
+		c.Listen = defaultListen
+	}
+	if len(c.ClaimHeaders) == 0 {
+		c.ClaimHeaders = defaultClaimHeaders
+	}
+	c.shutdownTimeout = defaultShutdownTimeout
+	if len(c.ShutdownTimeout) > 0 {
+		timeout, err := time.ParseDuration(c.ShutdownTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse shutdown timeout: %w", err)
+		}
+		c.shutdownTimeout = timeout
+	}
+	return c, nil
+}
+
+// decodeYAML Decodes the YAML document into v, matching mapping keys with
+// the JSON names of fields like encoding/json
+func decodeYAML(data []byte, v interface{}) error {
+	node, err := parseYAML(data)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return nil
+	}
+	return decodeNode(node, reflect.ValueOf(v).Elem(), "")
+}
+
+// decodeEnv Overrides the fields of v with the environment variables named
+// after their JSON name, such as FORWARDAUTH_JWKS_URL for jwksURL. Lists of
+// strings are comma separated, and other lists and maps are JSON encoded.
+func decodeEnv(environ []string, v interface{}) error {
+	root := reflect.ValueOf(v).Elem()
+	fields := jsonFields(root.Type())
+
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if i := strings.IndexByte(kv, '='); i > 0 && strings.HasPrefix(kv, envPrefix) {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	for _, f := range fields {
+		name := envPrefix + envName(f.name)
+		value, ok := env[name]
+		if !ok {
+			continue
+		}
+
+		field := root.FieldByIndex(f.index)
+		var err error
+		switch {
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+			var items []interface{}
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); len(item) > 0 {
+					items = append(items, item)
+				}
+			}
+			err = decodeNode(items, field, name)
+		case field.Kind() == reflect.Slice, field.Kind() == reflect.Map:
+			err = json.Unmarshal([]byte(value), field.Addr().Interface())
+		default:
+			err = decodeNode(value, field, name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// decodeNode Decodes the parsed YAML node into v, resolving the type of
+// scalars from the type of v
+func decodeNode(node interface{}, v reflect.Value, path string) error {
+	if node == nil {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		s, ok := node.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a string", path)
+		}
+		v.SetString(s)
+	case reflect.Bool:
+		s, _ := node.(string)
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%s: expected a boolean", path)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s, _ := node.(string)
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s: expected an integer", path)
+		}
+		v.SetInt(i)
+	case reflect.Slice:
+		items, ok := node.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected a list", path)
+		}
+		s := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeNode(item, s.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.Map:
+		entries, ok := node.(map[string]interface{})
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("%s: expected a mapping", path)
+		}
+		m := reflect.MakeMapWithSize(v.Type(), len(entries))
+		for key, entry := range entries {
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeNode(entry, value, joinPath(path, key)); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), value)
+		}
+		v.Set(m)
+	case reflect.Struct:
+		entries, ok := node.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected a mapping", path)
+		}
+		fields := jsonFields(v.Type())
+		for key, entry := range entries {
+			f, ok := fields[strings.ToLower(key)]
+			if !ok {
+				return fmt.Errorf("%s: unknown field", joinPath(path, key))
+			}
+			if err := decodeNode(entry, v.FieldByIndex(f.index), joinPath(path, key)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unsupported field type %s", path, v.Type())
+	}
+	return nil
+}
+
+type jsonField struct {
+	name  string
+	index []int
+}
+
+// jsonFields Returns the fields of the struct type by lower cased JSON name,
+// including the fields of embedded structs
+func jsonFields(t reflect.Type) map[string]jsonField {
+	fields := make(map[string]jsonField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			for key, embedded := range jsonFields(f.Type) {
+				if _, ok := fields[key]; !ok {
+					fields[key] = jsonField{name: embedded.name, index: append([]int{i}, embedded.index...)}
+				}
+			}
+			continue
+		}
+		if len(f.PkgPath) > 0 {
+			continue
+		}
+
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if len(name) == 0 {
+			name = f.Name
+		}
+		fields[strings.ToLower(name)] = jsonField{name: name, index: []int{i}}
+	}
+	return fields
+}
+
+// envName Converts the camel case JSON name of a field to the upper snake
+// case name of its environment variable, such as JWKS_URL for jwksURL
+func envName(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				(unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+func joinPath(path, key string) string {
+	if len(path) == 0 {
+		return key
+	}
+	return path + "." + key
+}
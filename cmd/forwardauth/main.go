@@ -0,0 +1,153 @@
+// Command forwardauth serves the JWT middleware as an authentication server
+// for Traefik's ForwardAuth middleware, for clusters where plugins cannot be
+// used. Requests to /auth are verified as described by the X-Forwarded-*
+// headers set by Traefik. DPoP proofs are only checked against the
+// forwarded scheme and host if Traefik is one of the trustedProxies.
+//
+// The configuration uses the same fields as the plugin, from a YAML or JSON
+// file given with -config, and from FORWARDAUTH_* environment variables:
+//
+//	listen: ":8080"
+//	jwksURL: https://issuer.example.com/.well-known/jwks.json
+//	issuer: https://issuer.example.com
+//	claimHeaders:
+//	  X-Auth-Subject: sub
+//	  X-Auth-Email: email
+//
+// The configuration is reloaded on SIGHUP, keeping the previous one if the
+// new one is invalid. Used tokens and DPoP proofs, and the metrics, are kept
+// across reloads. The server shuts down gracefully on SIGINT and SIGTERM.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// authPath is the path of the endpoint Traefik's ForwardAuth middleware
+// is configured with
+const authPath = "/auth"
+
+func main() {
+	path := flag.String("config", os.Getenv(envPrefix+"CONFIG_FILE"), "path of the YAML or JSON configuration file")
+	flag.Parse()
+
+	if err := run(*path); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(path string) error {
+	c, err := loadConfig(path, os.Environ())
+	if err != nil {
+		return err
+	}
+	s := &server{}
+	if err := s.load(c); err != nil {
+		return err
+	}
+
+	srv := &http.Server{Addr: c.Listen, Handler: s}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+	log.Printf("listening on %s", c.Listen)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	for {
+		select {
+		case err := <-errs:
+			return err
+		case sig := <-signals:
+			if sig != syscall.SIGHUP {
+				log.Printf("shutting down on %s", sig)
+				return shutdown(srv, c.shutdownTimeout)
+			}
+
+			reloaded, err := loadConfig(path, os.Environ())
+			if err == nil {
+				err = s.load(reloaded)
+			}
+			if err != nil {
+				log.Printf("keeping previous configuration, failed to reload: %s", err)
+				continue
+			}
+			if reloaded.Listen != c.Listen {
+				log.Printf("ignoring new listen address %s until restart", reloaded.Listen)
+				reloaded.Listen = c.Listen
+			}
+			c = reloaded
+			log.Print("configuration reloaded")
+		}
+	}
+}
+
+// shutdown Stops accepting requests, and waits for the requests in flight
+// for at most the given timeout
+func shutdown(srv *http.Server, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// server routes requests to the handlers built from the current
+// configuration, which are replaced as a whole on reload
+type server struct {
+	mux atomic.Value
+	// auth is the current auth handler, only used by load
+	auth *authHandler
+}
+
+// load Builds the handlers for the given configuration, and swaps them in.
+// The middleware is reloaded from the previous one if any, so that replay
+// protection and metrics carry over.
+func (s *server) load(c *config) error {
+	paths := map[string]bool{authPath: true}
+	for _, path := range []string{c.MetricsPath, c.InternalJwksPath} {
+		if len(path) == 0 {
+			continue
+		}
+		if paths[path] {
+			return fmt.Errorf("path %s is already in use", path)
+		}
+		paths[path] = true
+	}
+	if len(c.InternalJwksPath) > 0 && len(c.InternalTokenKey) == 0 {
+		return errors.New("internal JWKS path requires an internal token key")
+	}
+
+	auth, err := newAuthHandler(c, s.auth)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(authPath, auth)
+	if len(c.MetricsPath) > 0 {
+		mux.Handle(c.MetricsPath, auth.middleware.MetricsHandler())
+	}
+	if len(c.InternalJwksPath) > 0 {
+		mux.Handle(c.InternalJwksPath, auth.middleware.InternalJwksHandler())
+	}
+	s.auth = auth
+	s.mux.Store(mux)
+	return nil
+}
+
+func (s *server) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	s.mux.Load().(*http.ServeMux).ServeHTTP(res, req)
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	traefik_jwt_middleware "github.com/whlanuo/traefik-jwt-middleware"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwa"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwk"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwt"
+)
+
+const testKey = "{\"kty\":\"oct\",\"use\":\"sig\",\"kid\":\"default\",\"k\":\"MWNhZjc2YV4xJWE0QjU2NTYqNCZmYzIoYjAxMzVjMmU=\",\"alg\":\"HS256\"}"
+
+func TestParseYAML(t *testing.T) {
+	doc := `---
+# comment
+name: plain value # trailing comment
+quoted: "a \"b\" # c\u00e9"
+single: 'it''s'
+url: https://example.com/path#fragment
+empty:
+list:
+- a
+- "b"
+flow: [a, 'b, c', "d"]
+nested:
+  flow: {x: 1, "y": two}
+  items:
+    - pathPrefix: /public/
+      methods: [GET, HEAD]
+    - host: "*.example.com"
+literal: |
+  line 1
+    line 2
+
+folded: >-
+  folded
+  text
+
+  paragraph
+`
+	v, err := parseYAML([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"name":   "plain value",
+		"quoted": "a \"b\" # c\u00e9",
+		"single": "it's",
+		"url":    "https://example.com/path#fragment",
+		"empty":  nil,
+		"list":   []interface{}{"a", "b"},
+		"flow":   []interface{}{"a", "b, c", "d"},
+		"nested": map[string]interface{}{
+			"flow": map[string]interface{}{"x": "1", "y": "two"},
+			"items": []interface{}{
+				map[string]interface{}{"pathPrefix": "/public/", "methods": []interface{}{"GET", "HEAD"}},
+				map[string]interface{}{"host": "*.example.com"},
+			},
+		},
+		"literal": "line 1\n  line 2\n",
+		"folded":  "folded text\nparagraph",
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("expected %#v, got %#v", expected, v)
+	}
+
+	for _, invalid := range []string{
+		"a: 1\na: 2",
+		"a: [1, 2",
+		"a:\n\tb: 1",
+		"a: 1\n  b: 2",
+		"a: \"unterminated",
+	} {
+		if _, err := parseYAML([]byte(invalid)); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forwardauth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	yamlPath := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(yamlPath, []byte(`
+issuer: https://issuer.example.com
+tokenCacheSize: 100
+replayProtection: true
+trustedProxies: [10.0.0.0/8]
+bypass:
+  - pathPrefix: /public/
+    methods: [GET]
+claimHeaders:
+  X-Auth-Email: email
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := loadConfig(yamlPath, []string{
+		"FORWARDAUTH_JWKS_URL=https://issuer.example.com/jwks",
+		"FORWARDAUTH_TOKEN_CACHE_SIZE=10",
+		"FORWARDAUTH_INTERNAL_TOKEN_CLAIMS=sub, email",
+		"FORWARDAUTH_LISTEN=:9090",
+		"OTHER_ISSUER=ignored",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := traefik_jwt_middleware.Config{
+		Issuer:              "https://issuer.example.com",
+		JwksURL:             "https://issuer.example.com/jwks",
+		TokenCacheSize:      10,
+		ReplayProtection:    true,
+		TrustedProxies:      []string{"10.0.0.0/8"},
+		InternalTokenClaims: []string{"sub", "email"},
+		Bypass:              []traefik_jwt_middleware.BypassRule{{PathPrefix: "/public/", Methods: []string{"GET"}}},
+	}
+	if !reflect.DeepEqual(c.Config, expected) {
+		t.Errorf("expected %+v, got %+v", expected, c.Config)
+	}
+	if c.Listen != ":9090" || !reflect.DeepEqual(c.ClaimHeaders, map[string]string{"X-Auth-Email": "email"}) {
+		t.Errorf("unexpected server config %+v", c)
+	}
+
+	jsonPath := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(jsonPath, []byte(`{"issuer": "https://issuer.example.com", "optionalAuth": true}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err = loadConfig(jsonPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Issuer != "https://issuer.example.com" || !c.OptionalAuth || c.Listen != defaultListen || c.shutdownTimeout != defaultShutdownTimeout {
+		t.Errorf("unexpected config %+v", c)
+	}
+
+	if err := ioutil.WriteFile(yamlPath, []byte("unknownField: true\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(yamlPath, nil); err == nil {
+		t.Error("expected unknown fields to be rejected")
+	}
+	if _, err := loadConfig("", []string{"FORWARDAUTH_TOKEN_CACHE_SIZE=many"}); err == nil {
+		t.Error("expected invalid environment variables to be rejected")
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"jwksURL":               "JWKS_URL",
+		"publicKeyPEM":          "PUBLIC_KEY_PEM",
+		"introspectionClientID": "INTROSPECTION_CLIENT_ID",
+		"idTokenMaxAge":         "ID_TOKEN_MAX_AGE",
+		"internalTokenTTL":      "INTERNAL_TOKEN_TTL",
+		"dpop":                  "DPOP",
+	} {
+		if actual := envName(name); actual != expected {
+			t.Errorf("expected %s for %s, got %s", expected, name, actual)
+		}
+	}
+}
+
+func TestAuthHandler(t *testing.T) {
+	keySet, err := jwk.ParseString(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signKey, _ := keySet.Get(0)
+	tok := jwt.New()
+	for name, value := range map[string]interface{}{jwt.SubjectKey: "alice", jwt.AudienceKey: []string{"api", "web"}, "email": "alice@example.com"} {
+		if err := tok.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	signed, err := jwt.Sign(tok, jwa.HS256, signKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tok.Set(jwt.AudienceKey, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	otherAudience, err := jwt.Sign(tok, jwa.HS256, signKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := loadConfig("", []string{
+		"FORWARDAUTH_SECRET=" + testKey,
+		"FORWARDAUTH_AUDIENCE=api",
+		"FORWARDAUTH_METRICS_PATH=/metrics",
+		`FORWARDAUTH_BYPASS=[{"pathPrefix": "/public/"}]`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ClaimHeaders = map[string]string{"X-Auth-Subject": "sub", "X-Auth-Email": "email", "X-Auth-Audience": "aud"}
+	s := &server{}
+	if err := s.load(c); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		uri      string
+		headers  map[string]string
+		expected int
+		response map[string]string
+	}{
+		{
+			name:     "valid token",
+			uri:      "/api",
+			headers:  map[string]string{"Authorization": "Bearer " + string(signed)},
+			expected: http.StatusOK,
+			response: map[string]string{
+				"X-Auth-Subject":  "alice",
+				"X-Auth-Email":    "alice@example.com",
+				"X-Auth-Audience": "api,web",
+				"injectedPayload": string(signed),
+			},
+		},
+		{
+			name:     "missing token",
+			uri:      "/api",
+			expected: http.StatusUnauthorized,
+			response: map[string]string{"WWW-Authenticate": "Bearer"},
+		},
+		{
+			name:     "invalid token",
+			uri:      "/api",
+			headers:  map[string]string{"Authorization": "Bearer invalid"},
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:     "token for another audience",
+			uri:      "/api",
+			headers:  map[string]string{"Authorization": "Bearer " + string(otherAudience)},
+			expected: http.StatusForbidden,
+		},
+		{
+			name:     "bypassed path",
+			uri:      "/public/logo.png",
+			headers:  map[string]string{"injectedPayload": "forged"},
+			expected: http.StatusOK,
+			response: map[string]string{"X-Auth-Subject": "", "injectedPayload": ""},
+		},
+		{
+			name:     "metrics path is not served to forwarded requests",
+			uri:      "/metrics",
+			expected: http.StatusUnauthorized,
+		},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "http://forwardauth:8080/auth", nil)
+		req.Header.Set("X-Forwarded-Method", http.MethodGet)
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Host", "api.example.com")
+		req.Header.Set("X-Forwarded-Uri", tc.uri)
+		for name, value := range tc.headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != tc.expected {
+			t.Errorf("%s: expected status %d, got %d", tc.name, tc.expected, rec.Code)
+		}
+		for name, value := range tc.response {
+			if actual := rec.Header().Get(name); actual != value {
+				t.Errorf("%s: expected %s header %q, got %q", tc.name, name, value, actual)
+			}
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected metrics to be served, got status %d", rec.Code)
+	}
+
+	c.InternalJwksPath = "/jwks"
+	if err := s.load(c); err == nil {
+		t.Error("expected internal JWKS path without a key to be rejected")
+	}
+}
+
+func TestReload(t *testing.T) {
+	keySet, err := jwk.ParseString(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signKey, _ := keySet.Get(0)
+	tok := jwt.New()
+	for name, value := range map[string]interface{}{jwt.SubjectKey: "alice", jwt.JwtIDKey: "payment-1", jwt.ExpirationKey: time.Now().Add(time.Hour)} {
+		if err := tok.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	signed, err := jwt.Sign(tok, jwa.HS256, signKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	environ := []string{
+		"FORWARDAUTH_SECRET=" + testKey,
+		"FORWARDAUTH_REPLAY_PROTECTION=true",
+		"FORWARDAUTH_METRICS_PATH=/metrics",
+	}
+	c, err := loadConfig("", environ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{}
+	if err := s.load(c); err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func() int {
+		req := httptest.NewRequest(http.MethodGet, "http://forwardauth:8080/auth", nil)
+		req.Header.Set("X-Forwarded-Method", http.MethodGet)
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Host", "api.example.com")
+		req.Header.Set("X-Forwarded-Uri", "/api")
+		req.Header.Set("Authorization", "Bearer "+string(signed))
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve(); code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+	}
+
+	c, err = loadConfig("", append(environ, "FORWARDAUTH_REPLAY_CACHE_SIZE=100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.load(c); err != nil {
+		t.Fatal(err)
+	}
+	if code := serve(); code != http.StatusUnauthorized {
+		t.Errorf("expected token replayed after reload to be rejected with status %d, got %d", http.StatusUnauthorized, code)
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, expected := range []string{
+		`jwt_requests_total{middleware="forwardauth",outcome="allowed",reason="none"} 1`,
+		`jwt_requests_total{middleware="forwardauth",outcome="denied",reason="replayed"} 1`,
+	} {
+		if !strings.Contains(rec.Body.String(), expected) {
+			t.Errorf("expected metrics kept across reload to contain %s, got:\n%s", expected, rec.Body.String())
+		}
+	}
+
+	// an invalid configuration keeps the previous one
+	c.InternalJwksPath = "/metrics"
+	if err := s.load(c); err == nil {
+		t.Error("expected path conflict to be rejected")
+	}
+	if code := serve(); code != http.StatusUnauthorized {
+		t.Errorf("expected previous configuration to be kept, got status %d", code)
+	}
+}
@@ -0,0 +1,533 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseYAML Parses the subset of YAML needed for configuration files, without
+// depending on a YAML library: block mappings and sequences, flow sequences
+// and mappings, plain, single and double quoted scalars, literal ("|") and
+// folded (">") block scalars, and comments. Anchors, tags and multi-line
+// plain scalars are not supported.
+//
+// Mappings are returned as map[string]interface{}, sequences as
+// []interface{}, null as nil, and all other scalars as strings: their type is
+// only resolved when decoding them into the configuration.
+func parseYAML(data []byte) (interface{}, error) {
+	p := &yamlParser{lines: strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n")}
+	if err := p.skipBlank(); err != nil {
+		return nil, err
+	}
+	if p.eof() {
+		return nil, nil
+	}
+
+	indent, _, err := p.current()
+	if err != nil {
+		return nil, err
+	}
+	v, err := p.parseBlock(indent)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.skipBlank(); err != nil {
+		return nil, err
+	}
+	if !p.eof() {
+		return nil, p.errorf("unexpected content")
+	}
+	return v, nil
+}
+
+type yamlParser struct {
+	lines []string
+	pos   int
+}
+
+func (p *yamlParser) eof() bool {
+	return p.pos >= len(p.lines)
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// current Returns the indentation of the current line, and its content
+// without the indentation and comments
+func (p *yamlParser) current() (int, string, error) {
+	line := p.lines[p.pos]
+	indent := len(line) - len(strings.TrimLeft(line, " "))
+	if indent < len(line) && line[indent] == '\t' {
+		return 0, "", p.errorf("tabs are not allowed for indentation")
+	}
+	return indent, stripComment(line[indent:]), nil
+}
+
+// skipBlank Skips empty lines, comments and document markers
+func (p *yamlParser) skipBlank() error {
+	for !p.eof() {
+		_, text, err := p.current()
+		if err != nil {
+			return err
+		}
+		if len(text) > 0 && text != "---" {
+			return nil
+		}
+		p.pos++
+	}
+	return nil
+}
+
+// parseBlock Parses the block sequence or mapping starting on the current
+// line, at the given indentation
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	_, text, err := p.current()
+	if err != nil {
+		return nil, err
+	}
+	if isSequenceItem(text) {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := splitKey(text); !ok {
+		// a single scalar or flow collection as the whole document
+		p.pos++
+		return p.parseInline(text)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *yamlParser) parseMapping(indent int) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	for {
+		if err := p.skipBlank(); err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			return m, nil
+		}
+
+		i, text, err := p.current()
+		if err != nil {
+			return nil, err
+		}
+		if i < indent {
+			return m, nil
+		}
+		if i > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		if isSequenceItem(text) {
+			return nil, p.errorf("expected a mapping key")
+		}
+
+		key, rest, ok := splitKey(text)
+		if !ok {
+			return nil, p.errorf("expected a mapping key")
+		}
+		if _, ok := m[key]; ok {
+			return nil, p.errorf("duplicate key %#v", key)
+		}
+		p.pos++
+
+		v, err := p.parseValue(rest, indent, true)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+}
+
+func (p *yamlParser) parseSequence(indent int) ([]interface{}, error) {
+	s := make([]interface{}, 0)
+	for {
+		if err := p.skipBlank(); err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			return s, nil
+		}
+
+		i, text, err := p.current()
+		if err != nil {
+			return nil, err
+		}
+		if i < indent {
+			return s, nil
+		}
+		if i > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		if !isSequenceItem(text) {
+			return s, nil
+		}
+
+		rest := strings.TrimLeft(text[1:], " ")
+		if _, _, isKey := splitKey(rest); isKey || isSequenceItem(rest) {
+			// a compact nested collection such as "- key: value": parse it
+			// as if the dash was indentation
+			offset := i + len(text) - len(rest)
+			line := p.lines[p.pos]
+			p.lines[p.pos] = strings.Repeat(" ", offset) + line[offset:]
+
+			v, err := p.parseBlock(offset)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+			continue
+		}
+
+		p.pos++
+		v, err := p.parseValue(rest, indent, false)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, v)
+	}
+}
+
+// parseValue Parses the value of a mapping entry or sequence item whose
+// inline content is rest. Values on the following lines must be indented
+// more than the parent, except for sequences in mappings.
+func (p *yamlParser) parseValue(rest string, parentIndent int, inMapping bool) (interface{}, error) {
+	if strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">") {
+		return p.parseBlockScalar(rest, parentIndent)
+	}
+	if len(rest) > 0 {
+		return p.parseInline(rest)
+	}
+
+	if err := p.skipBlank(); err != nil {
+		return nil, err
+	}
+	if p.eof() {
+		return nil, nil
+	}
+	i, text, err := p.current()
+	if err != nil {
+		return nil, err
+	}
+	if i > parentIndent || (inMapping && i == parentIndent && isSequenceItem(text)) {
+		return p.parseBlock(i)
+	}
+	return nil, nil
+}
+
+// parseInline Parses the inline value of the previous line
+func (p *yamlParser) parseInline(text string) (interface{}, error) {
+	v, err := parseFlow(text)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", p.pos, err)
+	}
+	return v, nil
+}
+
+// parseBlockScalar Parses a literal or folded block scalar with the given
+// header, with its "strip" ("-"), "clip" (default) or "keep" ("+") chomping
+// indicator
+func (p *yamlParser) parseBlockScalar(header string, parentIndent int) (string, error) {
+	folded := header[0] == '>'
+	chomping := header[1:]
+	if chomping != "" && chomping != "-" && chomping != "+" {
+		return "", p.errorf("unsupported block scalar header %#v", header)
+	}
+
+	var lines []string
+	indent := -1
+	for ; !p.eof(); p.pos++ {
+		line := p.lines[p.pos]
+		if len(strings.TrimSpace(line)) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		i := len(line) - len(strings.TrimLeft(line, " "))
+		if indent < 0 {
+			if i <= parentIndent {
+				break
+			}
+			indent = i
+		}
+		if i < indent {
+			break
+		}
+		lines = append(lines, line[indent:])
+	}
+
+	// trailing empty lines are only kept with the "keep" indicator
+	content := len(lines)
+	for content > 0 && len(lines[content-1]) == 0 {
+		content--
+	}
+	trailing := len(lines) - content
+	lines = lines[:content]
+
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			// folding joins lines with a space, and an empty line stands
+			// for the line break; more indented lines are kept as is
+			prev := lines[i-1]
+			foldable := folded && len(prev) > 0 && prev[0] != ' '
+			switch {
+			case foldable && len(line) > 0 && line[0] != ' ':
+				b.WriteByte(' ')
+			case foldable && len(line) == 0:
+			default:
+				b.WriteByte('\n')
+			}
+		}
+		b.WriteString(line)
+	}
+
+	switch {
+	case content == 0:
+	case chomping == "-":
+	case chomping == "+":
+		b.WriteString(strings.Repeat("\n", trailing+1))
+	default:
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// isSequenceItem Reports whether the line content starts a sequence item
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitKey Splits the line content of a mapping entry into its key and
+// inline value, and reports whether it is a mapping entry
+func splitKey(text string) (string, string, bool) {
+	var key string
+	var rest string
+	switch {
+	case len(text) == 0, text[0] == '[', text[0] == '{', isSequenceItem(text):
+		return "", "", false
+	case text[0] == '"' || text[0] == '\'':
+		v, n, err := parseQuoted(text)
+		if err != nil {
+			return "", "", false
+		}
+		rest = strings.TrimLeft(text[n:], " ")
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", false
+		}
+		key, rest = v, rest[1:]
+	default:
+		i := strings.Index(text, ": ")
+		if i < 0 {
+			if !strings.HasSuffix(text, ":") {
+				return "", "", false
+			}
+			i = len(text) - 1
+		}
+		key, rest = strings.TrimSpace(text[:i]), text[i+1:]
+	}
+
+	if len(rest) > 0 && rest[0] != ' ' {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(rest), true
+}
+
+// stripComment Removes the comment from the line content, if any
+func stripComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" [{,:-", text[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return strings.TrimRight(text[:i], " \t")
+		}
+	}
+	return strings.TrimRight(text, " \t")
+}
+
+// parseFlow Parses an inline scalar or flow collection, which must span the
+// whole text
+func parseFlow(text string) (interface{}, error) {
+	v, n, err := parseFlowValue(text, false)
+	if err != nil {
+		return nil, err
+	}
+	if rest := strings.TrimSpace(text[n:]); len(rest) > 0 {
+		return nil, fmt.Errorf("unexpected %#v after value", rest)
+	}
+	return v, nil
+}
+
+// parseFlowValue Parses the value at the start of text, and returns the
+// number of bytes consumed. Plain scalars inside flow collections end at the
+// first flow indicator.
+func parseFlowValue(text string, inFlow bool) (interface{}, int, error) {
+	n := len(text) - len(strings.TrimLeft(text, " "))
+	text = text[n:]
+	if len(text) == 0 {
+		return nil, n, nil
+	}
+
+	switch text[0] {
+	case '"', '\'':
+		v, m, err := parseQuoted(text)
+		return v, n + m, err
+	case '[':
+		v, m, err := parseFlowSequence(text)
+		return v, n + m, err
+	case '{':
+		v, m, err := parseFlowMapping(text)
+		return v, n + m, err
+	}
+
+	end := len(text)
+	if inFlow {
+		if i := strings.IndexAny(text, ",]}"); i >= 0 {
+			end = i
+		}
+	}
+	plain := strings.TrimSpace(text[:end])
+	switch plain {
+	case "", "~", "null", "Null", "NULL":
+		return nil, n + end, nil
+	}
+	return plain, n + end, nil
+}
+
+func parseFlowSequence(text string) ([]interface{}, int, error) {
+	s := make([]interface{}, 0)
+	i := 1
+	for {
+		i += len(text[i:]) - len(strings.TrimLeft(text[i:], " "))
+		if i >= len(text) {
+			return nil, 0, errors.New("unterminated flow sequence")
+		}
+		if text[i] == ']' {
+			return s, i + 1, nil
+		}
+
+		v, n, err := parseFlowValue(text[i:], true)
+		if err != nil {
+			return nil, 0, err
+		}
+		s = append(s, v)
+		i += n
+
+		i += len(text[i:]) - len(strings.TrimLeft(text[i:], " "))
+		if i < len(text) && text[i] == ',' {
+			i++
+		} else if i >= len(text) || text[i] != ']' {
+			return nil, 0, errors.New("expected \",\" or \"]\" in flow sequence")
+		}
+	}
+}
+
+func parseFlowMapping(text string) (map[string]interface{}, int, error) {
+	m := make(map[string]interface{})
+	i := 1
+	for {
+		i += len(text[i:]) - len(strings.TrimLeft(text[i:], " "))
+		if i >= len(text) {
+			return nil, 0, errors.New("unterminated flow mapping")
+		}
+		if text[i] == '}' {
+			return m, i + 1, nil
+		}
+
+		var key string
+		if text[i] == '"' || text[i] == '\'' {
+			v, n, err := parseQuoted(text[i:])
+			if err != nil {
+				return nil, 0, err
+			}
+			key = v
+			i += n
+			i += len(text[i:]) - len(strings.TrimLeft(text[i:], " "))
+			if i >= len(text) || text[i] != ':' {
+				return nil, 0, errors.New("expected \":\" in flow mapping")
+			}
+		} else {
+			j := strings.IndexByte(text[i:], ':')
+			if j < 0 {
+				return nil, 0, errors.New("expected \":\" in flow mapping")
+			}
+			key = strings.TrimSpace(text[i : i+j])
+			i += j
+		}
+		i++
+
+		v, n, err := parseFlowValue(text[i:], true)
+		if err != nil {
+			return nil, 0, err
+		}
+		m[key] = v
+		i += n
+
+		i += len(text[i:]) - len(strings.TrimLeft(text[i:], " "))
+		if i < len(text) && text[i] == ',' {
+			i++
+		} else if i >= len(text) || text[i] != '}' {
+			return nil, 0, errors.New("expected \",\" or \"}\" in flow mapping")
+		}
+	}
+}
+
+// parseQuoted Parses the single or double quoted scalar at the start of
+// text, and returns the number of bytes consumed
+func parseQuoted(text string) (string, int, error) {
+	quote := text[0]
+	var b strings.Builder
+	for i := 1; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			b.WriteByte('\'')
+			i++
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\\' && quote == '"':
+			if i+1 >= len(text) {
+				return "", 0, errors.New("unterminated escape sequence")
+			}
+			i++
+			switch e := text[i]; e {
+			case '"', '\\', '/':
+				b.WriteByte(e)
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '0':
+				b.WriteByte(0)
+			case 'u':
+				if i+5 > len(text) {
+					return "", 0, errors.New("invalid unicode escape sequence")
+				}
+				r, err := strconv.ParseUint(text[i+1:i+5], 16, 32)
+				if err != nil {
+					return "", 0, errors.New("invalid unicode escape sequence")
+				}
+				var buf [utf8.UTFMax]byte
+				b.Write(buf[:utf8.EncodeRune(buf[:], rune(r))])
+				i += 4
+			default:
+				return "", 0, fmt.Errorf("unsupported escape sequence \\%c", e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, errors.New("unterminated quoted scalar")
+}
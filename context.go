@@ -0,0 +1,48 @@
+package traefik_jwt_middleware
+
+import (
+	"context"
+
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwk"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jws"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwt"
+)
+
+// VerifiedToken is a token that was verified by the middleware. It is
+// stored in the context of the requests forwarded with it, so that
+// in-process handlers can use it without parsing the token again. It may be
+// shared by requests carrying the same token, and must not be modified.
+type VerifiedToken struct {
+	// Token holds the claims of the token
+	Token jwt.Token
+	// Key is the key the token signature was verified with. It is nil for
+	// opaque tokens, which are introspected instead.
+	Key jwk.Key
+	// Headers are the protected JWS headers of the token. They are nil for
+	// opaque tokens.
+	Headers jws.Headers
+}
+
+type contextKey struct{}
+
+// NewContext Returns a copy of the context carrying the verified token
+func NewContext(ctx context.Context, tk *VerifiedToken) context.Context {
+	return context.WithValue(ctx, contextKey{}, tk)
+}
+
+// FromContext Returns the verified token carried by the context, and
+// whether there is one
+func FromContext(ctx context.Context) (*VerifiedToken, bool) {
+	tk, ok := ctx.Value(contextKey{}).(*VerifiedToken)
+	return tk, ok && tk != nil
+}
+
+// TokenFromContext Returns the claims of the verified token carried by the
+// context, and whether there is one
+func TokenFromContext(ctx context.Context) (jwt.Token, bool) {
+	tk, ok := FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	return tk.Token, true
+}
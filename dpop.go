@@ -0,0 +1,311 @@
+package traefik_jwt_middleware
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwa"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jws"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwt"
+)
+
+const (
+	dpopHeader = "DPoP"
+	dpopScheme = "DPoP"
+	// dpopType is the "typ" header of DPoP proofs
+	dpopType = "dpop+jwt"
+
+	defaultDPoPProofMaxAge = time.Minute
+)
+
+// Claims of DPoP proofs and DPoP-bound access tokens (RFC 9449)
+const (
+	dpopMethodKey          = "htm"
+	dpopURIKey             = "htu"
+	dpopAccessTokenHashKey = "ath"
+	confirmationKey        = "cnf"
+	jwkThumbprintKey       = "jkt"
+)
+
+var (
+	// errInvalidDPoPProof is returned when the DPoP proof is missing or
+	// invalid for the request
+	errInvalidDPoPProof = errors.New("invalid DPoP proof")
+	// errInvalidDPoPBinding is returned when the access token is not bound
+	// to the DPoP proof key as expected
+	errInvalidDPoPBinding = errors.New("invalid DPoP binding")
+)
+
+// dpopVerifier verifies DPoP proofs of possession, as described in RFC 9449.
+// Proofs are only accepted once: their JWT IDs are remembered until they are
+// too old to be accepted anyway.
+type dpopVerifier struct {
+	required bool
+	maxAge   time.Duration
+	skew     time.Duration
+	replay   *replayCache
+	trusted  trustedProxies
+}
+
+func newDPoPVerifier(required bool, maxAge, skew time.Duration, cacheSize int, trusted trustedProxies) *dpopVerifier {
+	return &dpopVerifier{
+		required: required,
+		maxAge:   maxAge,
+		skew:     skew,
+		replay:   newReplayCache(cacheSize, skew),
+		trusted:  trusted,
+	}
+}
+
+// splitDPoPScheme Splits the authorization header into its scheme and the
+// token, if the scheme is DPoP. Otherwise the scheme is empty, and the header
+// is returned as is.
+func splitDPoPScheme(header string) (string, string) {
+	if i := strings.IndexByte(header, ' '); i > 0 && strings.EqualFold(header[:i], dpopScheme) {
+		return dpopScheme, strings.TrimSpace(header[i+1:])
+	}
+	return "", header
+}
+
+// check Verifies that the request proves possession of the key the access
+// token is bound to. Tokens sent with the Bearer scheme must not be bound,
+// and are rejected if DPoP is required.
+func (d *dpopVerifier) check(req *http.Request, scheme, token string, tk jwt.Token) error {
+	jkt, bound := tokenThumbprint(tk)
+	if scheme != dpopScheme {
+		if bound {
+			return fmt.Errorf("%w: DPoP-bound token sent without proof", errInvalidDPoPBinding)
+		}
+		if d.required {
+			return fmt.Errorf("%w: DPoP-bound token required", errInvalidDPoPBinding)
+		}
+		return nil
+	}
+	if !bound {
+		return fmt.Errorf("%w: token is not DPoP-bound", errInvalidDPoPBinding)
+	}
+
+	proofs := req.Header.Values(dpopHeader)
+	if len(proofs) != 1 {
+		return fmt.Errorf("%w: expected exactly one %s header", errInvalidDPoPProof, dpopHeader)
+	}
+
+	proof, thumbprint, err := d.verifyProof(proofs[0], req, token)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidDPoPProof, err)
+	}
+	if subtle.ConstantTimeCompare([]byte(thumbprint), []byte(jkt)) != 1 {
+		return fmt.Errorf("%w: proof key does not match the %s.%s claim", errInvalidDPoPBinding, confirmationKey, jwkThumbprintKey)
+	}
+
+	// only remember proofs that are valid, so that invalid ones do not
+	// burn the JWT ID of a legitimate proof
+	until := proof.IssuedAt().Add(d.maxAge + d.skew)
+	if err := d.replay.record(thumbprint+"\x00"+proof.JwtID(), until); err != nil {
+		if err == errAlreadyRecorded {
+			return fmt.Errorf("%w: proof has already been used", errInvalidDPoPProof)
+		}
+		return fmt.Errorf("%w: %v", errInvalidDPoPProof, err)
+	}
+	return nil
+}
+
+// verifyProof Verifies the DPoP proof with the public key in its "jwk"
+// header, and checks its claims against the request and the access token.
+// It returns the proof and the RFC 7638 thumbprint of its key.
+func (d *dpopVerifier) verifyProof(proof string, req *http.Request, token string) (jwt.Token, string, error) {
+	msg, err := jws.ParseString(proof)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(msg.Signatures()) != 1 {
+		return nil, "", errors.New("expected exactly one signature")
+	}
+	hdrs := msg.Signatures()[0].ProtectedHeaders()
+
+	alg := hdrs.Algorithm()
+	if !isAsymmetric(alg) {
+		return nil, "", fmt.Errorf("unsupported algorithm %#v", alg.String())
+	}
+	key := hdrs.JWK()
+	if key == nil {
+		return nil, "", errors.New("missing jwk header")
+	}
+	var raw interface{}
+	if err := key.Raw(&raw); err != nil {
+		return nil, "", err
+	}
+	if isPrivateKey(raw) {
+		return nil, "", errors.New("jwk header contains a private key")
+	}
+	if keyAlg := key.Algorithm(); !keyMatchesAlgorithm(raw, alg) || (len(keyAlg) > 0 && keyAlg != alg.String()) {
+		return nil, "", fmt.Errorf("jwk header key cannot be used with algorithm %#v", alg.String())
+	}
+
+	tk, err := jwt.ParseString(proof, jwt.WithVerify(alg, raw), jwt.WithTypeHeader(dpopType))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if v, _ := tk.Get(dpopMethodKey); v != req.Method {
+		return nil, "", fmt.Errorf("%s claim does not match the request method", dpopMethodKey)
+	}
+	htu, _ := tk.Get(dpopURIKey)
+	if s, ok := htu.(string); !ok || !sameURI(s, requestURI(req, d.trusted)) {
+		return nil, "", fmt.Errorf("%s claim does not match the request URI", dpopURIKey)
+	}
+	if len(tk.JwtID()) == 0 {
+		return nil, "", fmt.Errorf("missing %s claim", jwt.JwtIDKey)
+	}
+
+	iat := tk.IssuedAt()
+	now := time.Now()
+	if iat.IsZero() || iat.After(now.Add(d.skew)) || iat.Add(d.maxAge+d.skew).Before(now) {
+		return nil, "", fmt.Errorf("%s claim is not within the acceptable window", jwt.IssuedAtKey)
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	ath, _ := tk.Get(dpopAccessTokenHashKey)
+	if s, ok := ath.(string); !ok || subtle.ConstantTimeCompare([]byte(s), []byte(base64.RawURLEncoding.EncodeToString(sum[:]))) != 1 {
+		return nil, "", fmt.Errorf("%s claim does not match the access token", dpopAccessTokenHashKey)
+	}
+
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, "", err
+	}
+	return tk, base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
+// tokenThumbprint Returns the "jkt" member of the "cnf" claim of the access
+// token, and whether it is present
+func tokenThumbprint(tk jwt.Token) (string, bool) {
+	v, ok := tk.Get(confirmationKey)
+	if !ok {
+		return "", false
+	}
+	cnf, ok := v.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	jkt, ok := cnf[jwkThumbprintKey].(string)
+	return jkt, ok && len(jkt) > 0
+}
+
+func isAsymmetric(alg jwa.SignatureAlgorithm) bool {
+	switch alg {
+	case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512,
+		jwa.ES256, jwa.ES384, jwa.ES512, jwa.EdDSA:
+		return true
+	default:
+		return false
+	}
+}
+
+// keyMatchesAlgorithm Reports whether the public key is of the type the
+// algorithm requires, and on its curve for ECDSA
+func keyMatchesAlgorithm(raw interface{}, alg jwa.SignatureAlgorithm) bool {
+	switch key := raw.(type) {
+	case *rsa.PublicKey:
+		switch alg {
+		case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512:
+			return true
+		}
+	case *ecdsa.PublicKey:
+		switch alg {
+		case jwa.ES256:
+			return key.Curve == elliptic.P256()
+		case jwa.ES384:
+			return key.Curve == elliptic.P384()
+		case jwa.ES512:
+			return key.Curve == elliptic.P521()
+		}
+	case ed25519.PublicKey:
+		return alg == jwa.EdDSA
+	}
+	return false
+}
+
+func isPrivateKey(raw interface{}) bool {
+	switch raw.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return true
+	default:
+		return false
+	}
+}
+
+// requestURI Returns the URI the client sent the request to. The
+// X-Forwarded-Proto and X-Forwarded-Host headers are only used for requests
+// from trusted proxies, since any client can set them.
+func requestURI(req *http.Request, trusted trustedProxies) *url.URL {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	host := req.Host
+	if trusted.contains(req.RemoteAddr) {
+		if proto := req.Header.Get("X-Forwarded-Proto"); len(proto) > 0 {
+			scheme = proto
+		}
+		if forwarded := req.Header.Get("X-Forwarded-Host"); len(forwarded) > 0 {
+			host = forwarded
+		}
+	}
+	return &url.URL{Scheme: scheme, Host: host, Path: req.URL.Path}
+}
+
+// sameURI Compares the "htu" claim with the request URI, ignoring the query
+// and fragment, as described in RFC 9449 section 4.3
+func sameURI(htu string, uri *url.URL) bool {
+	u, err := url.Parse(htu)
+	if err != nil {
+		return false
+	}
+	path := u.Path
+	if len(path) == 0 {
+		path = "/"
+	}
+	reqPath := uri.Path
+	if len(reqPath) == 0 {
+		reqPath = "/"
+	}
+	return strings.EqualFold(u.Scheme, uri.Scheme) &&
+		normalizeHost(u.Scheme, u.Host) == normalizeHost(uri.Scheme, uri.Host) &&
+		path == reqPath
+}
+
+// normalizeHost Lower cases the host and strips the default port of the
+// scheme
+func normalizeHost(scheme, host string) string {
+	host = strings.ToLower(host)
+	switch strings.ToLower(scheme) {
+	case "http":
+		return strings.TrimSuffix(host, ":80")
+	case "https":
+		return strings.TrimSuffix(host, ":443")
+	}
+	return host
+}
+
+// dpopChallenge Returns the WWW-Authenticate header for a request rejected
+// with the given error
+func dpopChallenge(err error) string {
+	code := "invalid_token"
+	if errors.Is(err, errInvalidDPoPProof) {
+		code = "invalid_dpop_proof"
+	}
+	return fmt.Sprintf(`DPoP error="%s", algs="RS256 PS256 ES256 EdDSA"`, code)
+}
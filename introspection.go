@@ -0,0 +1,140 @@
+package traefik_jwt_middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwt"
+)
+
+const (
+	introspectionTimeout            = 10 * time.Second
+	defaultIntrospectionCacheSize   = 1000
+	defaultIntrospectionCacheMaxAge = time.Minute
+	// introspectionInactiveTTL is how long tokens reported as not active
+	// are remembered, so that they are not introspected on every request
+	introspectionInactiveTTL = 10 * time.Second
+	// maxIntrospectionResponseSize bounds the size of introspection responses
+	maxIntrospectionResponseSize = 1 << 20
+)
+
+var (
+	// errInactiveToken is returned when the introspection endpoint reports
+	// the token as not active
+	errInactiveToken = errors.New("token is not active")
+	// errIntrospectionFailed is returned when the introspection endpoint
+	// could not be queried
+	errIntrospectionFailed = errors.New("token introspection failed")
+)
+
+// introspector resolves opaque tokens with an OAuth 2.0 token introspection
+// endpoint (RFC 7662). The claims of active tokens are returned as a
+// jwt.Token, so that they go through the same validation as JWT claims.
+//
+// Active tokens are cached until they expire, or for at most the max age of
+// the cache, after which they are introspected again. Inactive tokens are
+// cached for introspectionInactiveTTL, or the max age if shorter.
+type introspector struct {
+	url          string
+	clientID     string
+	clientSecret string
+	client       *http.Client
+	cache        *tokenCache
+	inactive     *tokenCache
+}
+
+func newIntrospector(endpoint, clientID, clientSecret string, cacheSize int, maxAge time.Duration) *introspector {
+	inactiveTTL := introspectionInactiveTTL
+	if maxAge > 0 && maxAge < inactiveTTL {
+		inactiveTTL = maxAge
+	}
+	return &introspector{
+		url:          endpoint,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		client:       &http.Client{Timeout: introspectionTimeout},
+		cache:        newTokenCache(cacheSize, maxAge),
+		inactive:     newTokenCache(cacheSize, inactiveTTL),
+	}
+}
+
+// Introspect Returns the claims of the given token if it is active
+func (i *introspector) Introspect(ctx context.Context, token string) (*VerifiedToken, error) {
+	if tk, ok := i.cache.Get(token, nil); ok {
+		return tk, nil
+	}
+	if _, ok := i.inactive.Get(token, nil); ok {
+		return nil, errInactiveToken
+	}
+
+	claims, err := i.fetch(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if active, _ := claims["active"].(bool); !active {
+		i.inactive.Add(token, nil, &VerifiedToken{Token: jwt.New()}, time.Time{})
+		return nil, errInactiveToken
+	}
+	delete(claims, "active")
+
+	tk := jwt.New()
+	for name, value := range claims {
+		if err := tk.Set(name, value); err != nil {
+			return nil, fmt.Errorf("%w: invalid %s claim: %v", errIntrospectionFailed, name, err)
+		}
+	}
+
+	verified := &VerifiedToken{Token: tk}
+	i.cache.Add(token, nil, verified, time.Time{})
+	return verified, nil
+}
+
+// fetch Posts the token to the introspection endpoint, authenticating with
+// the client credentials as described in RFC 6749 section 2.3.1
+func (i *introspector) fetch(ctx context.Context, token string) (map[string]interface{}, error) {
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("token_type_hint", "access_token")
+
+	req, err := http.NewRequest(http.MethodPost, i.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errIntrospectionFailed, err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if len(i.clientID) > 0 {
+		req.SetBasicAuth(url.QueryEscape(i.clientID), url.QueryEscape(i.clientSecret))
+	}
+
+	res, err := i.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errIntrospectionFailed, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		_, _ = io.Copy(ioutil.Discard, res.Body)
+		return nil, fmt.Errorf("%w: unexpected status %d", errIntrospectionFailed, res.StatusCode)
+	}
+
+	var claims map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxIntrospectionResponseSize)).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode response: %v", errIntrospectionFailed, err)
+	}
+	return claims, nil
+}
+
+// isOpaqueToken Reports whether the token is not a JWS in compact
+// serialization, and must be introspected
+func isOpaqueToken(token string) bool {
+	return strings.Count(token, ".") != 2
+}
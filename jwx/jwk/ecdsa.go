@@ -0,0 +1,187 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"fmt"
+	"math/big"
+
+	base642 "github.com/whlanuo/traefik-jwt-middleware/jwx/internal/base64"
+	blackmagic2 "github.com/whlanuo/traefik-jwt-middleware/jwx/internal/blackmagic"
+	jwa2 "github.com/whlanuo/traefik-jwt-middleware/jwx/jwa"
+
+	"github.com/whlanuo/traefik-jwt-middleware/errors"
+)
+
+func NewECDSAPublicKey() ECDSAPublicKey {
+	return newECDSAPublicKey()
+}
+
+func newECDSAPublicKey() *ecdsaPublicKey {
+	return &ecdsaPublicKey{
+		privateParams: make(map[string]interface{}),
+	}
+}
+
+func NewECDSAPrivateKey() ECDSAPrivateKey {
+	return newECDSAPrivateKey()
+}
+
+func newECDSAPrivateKey() *ecdsaPrivateKey {
+	return &ecdsaPrivateKey{
+		privateParams: make(map[string]interface{}),
+	}
+}
+
+func curveToAlgorithm(crv elliptic.Curve) (jwa2.EllipticCurveAlgorithm, error) {
+	switch crv {
+	case elliptic.P256():
+		return jwa2.P256, nil
+	case elliptic.P384():
+		return jwa2.P384, nil
+	case elliptic.P521():
+		return jwa2.P521, nil
+	default:
+		return jwa2.InvalidEllipticCurve, errors.Errorf(`unsupported elliptic curve %v`, crv.Params().Name)
+	}
+}
+
+func algorithmToCurve(alg jwa2.EllipticCurveAlgorithm) (elliptic.Curve, error) {
+	switch alg {
+	case jwa2.P256:
+		return elliptic.P256(), nil
+	case jwa2.P384:
+		return elliptic.P384(), nil
+	case jwa2.P521:
+		return elliptic.P521(), nil
+	default:
+		return nil, errors.Errorf(`unsupported elliptic curve algorithm %s`, alg)
+	}
+}
+
+// padBytes left-pads the given buffer with zeros so that its length
+// matches the size of the curve, as required by RFC 7518 section 6.2.1.2
+func padBytes(buf []byte, size int) []byte {
+	if len(buf) >= size {
+		return buf
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(buf):], buf)
+	return padded
+}
+
+func (k *ecdsaPublicKey) FromRaw(rawKey *ecdsa.PublicKey) error {
+	crv, err := curveToAlgorithm(rawKey.Curve)
+	if err != nil {
+		return errors.Wrap(err, `failed to determine curve`)
+	}
+
+	k.crv = &crv
+	k.x = padBytes(rawKey.X.Bytes(), crv.Size())
+	k.y = padBytes(rawKey.Y.Bytes(), crv.Size())
+	return nil
+}
+
+func (k *ecdsaPrivateKey) FromRaw(rawKey *ecdsa.PrivateKey) error {
+	crv, err := curveToAlgorithm(rawKey.Curve)
+	if err != nil {
+		return errors.Wrap(err, `failed to determine curve`)
+	}
+
+	k.crv = &crv
+	k.x = padBytes(rawKey.X.Bytes(), crv.Size())
+	k.y = padBytes(rawKey.Y.Bytes(), crv.Size())
+	k.d = padBytes(rawKey.D.Bytes(), crv.Size())
+	return nil
+}
+
+func buildECDSAPublicKey(alg jwa2.EllipticCurveAlgorithm, xbuf, ybuf []byte) (*ecdsa.PublicKey, error) {
+	crv, err := algorithmToCurve(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	var x, y big.Int // note: do not use from sync.Pool
+	x.SetBytes(xbuf)
+	y.SetBytes(ybuf)
+
+	if !crv.IsOnCurve(&x, &y) {
+		return nil, errors.New(`point is not on the curve`)
+	}
+
+	return &ecdsa.PublicKey{Curve: crv, X: &x, Y: &y}, nil
+}
+
+// Raw returns the EC-DSA public key represented by this JWK
+func (k *ecdsaPublicKey) Raw(v interface{}) error {
+	pubk, err := buildECDSAPublicKey(k.Crv(), k.x, k.y)
+	if err != nil {
+		return errors.Wrap(err, `failed to build public key`)
+	}
+
+	return blackmagic2.AssignIfCompatible(v, pubk)
+}
+
+// Raw returns the EC-DSA private key represented by this JWK
+func (k *ecdsaPrivateKey) Raw(v interface{}) error {
+	pubk, err := buildECDSAPublicKey(k.Crv(), k.x, k.y)
+	if err != nil {
+		return errors.Wrap(err, `failed to build public key`)
+	}
+
+	var key ecdsa.PrivateKey
+	var d big.Int // note: do not use from sync.Pool
+	d.SetBytes(k.d)
+	key.D = &d
+	key.PublicKey = *pubk
+
+	return blackmagic2.AssignIfCompatible(v, &key)
+}
+
+func (k ecdsaPrivateKey) PublicKey() (ECDSAPublicKey, error) {
+	var privk ecdsa.PrivateKey
+	if err := k.Raw(&privk); err != nil {
+		return nil, errors.Wrap(err, `failed to materialize ECDSA private key`)
+	}
+
+	newKey := NewECDSAPublicKey()
+	if err := newKey.FromRaw(&privk.PublicKey); err != nil {
+		return nil, errors.Wrap(err, `failed to initialize ECDSAPublicKey`)
+	}
+	return newKey, nil
+}
+
+// Thumbprint returns the JWK thumbprint using the indicated
+// hashing algorithm, according to RFC 7638
+func (k ecdsaPrivateKey) Thumbprint(hash crypto.Hash) ([]byte, error) {
+	var key ecdsa.PrivateKey
+	if err := k.Raw(&key); err != nil {
+		return nil, errors.Wrap(err, `failed to materialize ECDSA private key`)
+	}
+	return ecdsaThumbprint(hash, k.Crv(), &key.PublicKey)
+}
+
+func (k ecdsaPublicKey) Thumbprint(hash crypto.Hash) ([]byte, error) {
+	var key ecdsa.PublicKey
+	if err := k.Raw(&key); err != nil {
+		return nil, errors.Wrap(err, `failed to materialize ECDSA public key`)
+	}
+	return ecdsaThumbprint(hash, k.Crv(), &key)
+}
+
+func ecdsaThumbprint(hash crypto.Hash, crv jwa2.EllipticCurveAlgorithm, key *ecdsa.PublicKey) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `{"crv":"%s","kty":"EC","x":"`, crv)
+	buf.WriteString(base642.EncodeToString(padBytes(key.X.Bytes(), crv.Size())))
+	buf.WriteString(`","y":"`)
+	buf.WriteString(base642.EncodeToString(padBytes(key.Y.Bytes(), crv.Size())))
+	buf.WriteString(`"}`)
+
+	h := hash.New()
+	if _, err := buf.WriteTo(h); err != nil {
+		return nil, errors.Wrap(err, "failed to write ecdsaThumbprint")
+	}
+	return h.Sum(nil), nil
+}
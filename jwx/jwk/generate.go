@@ -0,0 +1,176 @@
+package jwk
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+
+	"github.com/whlanuo/traefik-jwt-middleware/errors"
+	base642 "github.com/whlanuo/traefik-jwt-middleware/jwx/internal/base64"
+	jwa2 "github.com/whlanuo/traefik-jwt-middleware/jwx/jwa"
+)
+
+const defaultRSAKeySize = 2048
+
+// Generate creates a new private key of the given key type, and
+// populates its "kid", "use" and "alg" fields.
+//
+// * jwa.RSA creates an RSA key (default 2048 bits, RS256)
+// * jwa.EC creates an EC key (default P-256, ES256)
+// * jwa.OKP creates an Ed25519 key (EdDSA)
+// * jwa.OctetSeq creates a symmetric key (default HS256, 256 bits)
+//
+// The defaults can be changed by passing WithKeySize, WithCurve,
+// WithAlgorithm, WithKeyUsage and WithKeyID. When no key ID is given,
+// the RFC 7638 thumbprint of the key is used, hashed with SHA-256
+// unless WithThumbprintHash is specified.
+func Generate(kty jwa2.KeyType, options ...Option) (Key, error) {
+	var size int
+	var crv jwa2.EllipticCurveAlgorithm
+	var kid string
+	var alg jwa2.SignatureAlgorithm
+	use := ForSignature
+	hash := crypto.SHA256
+	for _, option := range options {
+		switch option.Ident() {
+		case identKeySize{}:
+			size = option.Value().(int)
+		case identCurve{}:
+			crv = option.Value().(jwa2.EllipticCurveAlgorithm)
+		case identKeyID{}:
+			kid = option.Value().(string)
+		case identKeyUsage{}:
+			use = option.Value().(KeyUsageType)
+		case identAlgorithm{}:
+			alg = option.Value().(jwa2.SignatureAlgorithm)
+		case identThumbprintHash{}:
+			hash = option.Value().(crypto.Hash)
+		}
+	}
+
+	var raw interface{}
+	switch kty {
+	case jwa2.RSA:
+		switch alg {
+		case "":
+			alg = jwa2.RS256
+		case jwa2.RS256, jwa2.RS384, jwa2.RS512, jwa2.PS256, jwa2.PS384, jwa2.PS512:
+		default:
+			return nil, errors.Errorf(`invalid algorithm %s for RSA key`, alg)
+		}
+		if size == 0 {
+			size = defaultRSAKeySize
+		}
+
+		key, err := rsa.GenerateKey(rand.Reader, size)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to generate RSA key`)
+		}
+		raw = key
+	case jwa2.EC:
+		if crv == "" {
+			switch alg {
+			case jwa2.ES384:
+				crv = jwa2.P384
+			case jwa2.ES512:
+				crv = jwa2.P521
+			default:
+				crv = jwa2.P256
+			}
+		}
+		var expected jwa2.SignatureAlgorithm
+		switch crv {
+		case jwa2.P256:
+			expected = jwa2.ES256
+		case jwa2.P384:
+			expected = jwa2.ES384
+		case jwa2.P521:
+			expected = jwa2.ES512
+		}
+		if alg == "" {
+			alg = expected
+		} else if alg != expected {
+			return nil, errors.Errorf(`invalid algorithm %s for curve %s`, alg, crv)
+		}
+
+		curve, err := algorithmToCurve(crv)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to generate EC key`)
+		}
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to generate EC key`)
+		}
+		raw = key
+	case jwa2.OKP:
+		if crv != "" && crv != jwa2.Ed25519 {
+			return nil, errors.Errorf(`unsupported curve %s for OKP key`, crv)
+		}
+		if alg == "" {
+			alg = jwa2.EdDSA
+		} else if alg != jwa2.EdDSA {
+			return nil, errors.Errorf(`invalid algorithm %s for OKP key`, alg)
+		}
+
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to generate OKP key`)
+		}
+		raw = key
+	case jwa2.OctetSeq:
+		switch alg {
+		case "":
+			alg = jwa2.HS256
+		case jwa2.HS256, jwa2.HS384, jwa2.HS512:
+		default:
+			return nil, errors.Errorf(`invalid algorithm %s for symmetric key`, alg)
+		}
+		if size == 0 {
+			switch alg {
+			case jwa2.HS384:
+				size = 384
+			case jwa2.HS512:
+				size = 512
+			default:
+				size = 256
+			}
+		}
+		if size%8 != 0 {
+			return nil, errors.Errorf(`invalid size %d for symmetric key: must be a multiple of 8`, size)
+		}
+
+		octets := make([]byte, size/8)
+		if _, err := rand.Read(octets); err != nil {
+			return nil, errors.Wrap(err, `failed to generate symmetric key`)
+		}
+		raw = octets
+	default:
+		return nil, errors.Errorf(`invalid key type %s for jwk.Generate`, kty)
+	}
+
+	key, err := New(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create jwk.Key`)
+	}
+
+	if kid == "" {
+		tp, err := key.Thumbprint(hash)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to compute thumbprint`)
+		}
+		kid = base642.EncodeToString(tp)
+	}
+
+	if err := key.Set(KeyIDKey, kid); err != nil {
+		return nil, errors.Wrapf(err, `failed to set %s`, KeyIDKey)
+	}
+	if err := key.Set(KeyUsageKey, use); err != nil {
+		return nil, errors.Wrapf(err, `failed to set %s`, KeyUsageKey)
+	}
+	if err := key.Set(AlgorithmKey, alg); err != nil {
+		return nil, errors.Wrapf(err, `failed to set %s`, AlgorithmKey)
+	}
+	return key, nil
+}
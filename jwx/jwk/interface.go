@@ -2,10 +2,11 @@ package jwk
 
 import (
 	"crypto/x509"
-	iter2 "github.com/whlanuo/traefik-jwt-middleware/jwx/internal/iter"
+	"sync"
 
 	"github.com/whlanuo/traefik-jwt-middleware/iter/arrayiter"
 	"github.com/whlanuo/traefik-jwt-middleware/iter/mapiter"
+	iter2 "github.com/whlanuo/traefik-jwt-middleware/jwx/internal/iter"
 )
 
 // KeyUsageType is used to denote what this key should be used for
@@ -39,9 +40,15 @@ const (
 )
 
 // Set is a convenience struct to allow generating and parsing
-// JWK sets as opposed to single JWKs
+// JWK sets as opposed to single JWKs.
+//
+// A Set is safe for concurrent use. Keys are indexed by their key ID
+// at the time they are added to the set, so changing the "kid" of a
+// key after adding it is not reflected in LookupKeyID.
 type Set struct {
-	Keys []Key
+	mu    sync.RWMutex
+	keys  []Key
+	index map[string][]Key
 }
 
 type HeaderVisitor = iter2.MapVisitor
@@ -7,9 +7,9 @@ import (
 	"bytes"
 	"context"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"fmt"
-	"github.com/whlanuo/traefik-jwt-middleware/iter/arrayiter"
 	json2 "github.com/whlanuo/traefik-jwt-middleware/jwx/internal/json"
 	jwa2 "github.com/whlanuo/traefik-jwt-middleware/jwx/jwa"
 	"io"
@@ -62,6 +62,30 @@ func New(key interface{}) (Key, error) {
 			return nil, errors.Wrapf(err, `failed to initialize %T from %T`, k, rawKey)
 		}
 		return k, nil
+	case *ecdsa.PrivateKey:
+		k := NewECDSAPrivateKey()
+		if err := k.FromRaw(rawKey); err != nil {
+			return nil, errors.Wrapf(err, `failed to initialize %T from %T`, k, rawKey)
+		}
+		return k, nil
+	case *ecdsa.PublicKey:
+		k := NewECDSAPublicKey()
+		if err := k.FromRaw(rawKey); err != nil {
+			return nil, errors.Wrapf(err, `failed to initialize %T from %T`, k, rawKey)
+		}
+		return k, nil
+	case ed25519.PrivateKey:
+		k := NewOKPPrivateKey()
+		if err := k.FromRaw(rawKey); err != nil {
+			return nil, errors.Wrapf(err, `failed to initialize %T from %T`, k, rawKey)
+		}
+		return k, nil
+	case ed25519.PublicKey:
+		k := NewOKPPublicKey()
+		if err := k.FromRaw(rawKey); err != nil {
+			return nil, errors.Wrapf(err, `failed to initialize %T from %T`, k, rawKey)
+		}
+		return k, nil
 	case []byte:
 		k := NewSymmetricKey()
 		if err := k.FromRaw(rawKey); err != nil {
@@ -110,6 +134,14 @@ func PublicKeyOf(v interface{}) (interface{}, error) {
 		return &x.PublicKey, nil
 	case *rsa.PublicKey:
 		return x, nil
+	case *ecdsa.PrivateKey:
+		return &x.PublicKey, nil
+	case *ecdsa.PublicKey:
+		return x, nil
+	case ed25519.PrivateKey:
+		return x.Public(), nil
+	case ed25519.PublicKey:
+		return x, nil
 	case []byte:
 		return x, nil
 	default:
@@ -210,6 +242,18 @@ func ParseKey(data []byte) (Key, error) {
 		} else {
 			key = newRSAPublicKey()
 		}
+	case jwa2.EC:
+		if len(hint.D) > 0 {
+			key = newECDSAPrivateKey()
+		} else {
+			key = newECDSAPublicKey()
+		}
+	case jwa2.OKP:
+		if len(hint.D) > 0 {
+			key = newOKPPrivateKey()
+		} else {
+			key = newOKPPublicKey()
+		}
 	case jwa2.OctetSeq:
 		key = newSymmetricKey()
 	default:
@@ -223,33 +267,6 @@ func ParseKey(data []byte) (Key, error) {
 	return key, nil
 }
 
-func (s *Set) UnmarshalJSON(data []byte) error {
-	var proxy struct {
-		Keys []json2.RawMessage `json:"keys"`
-	}
-
-	if err := json2.Unmarshal(data, &proxy); err != nil {
-		return errors.Wrap(err, `failed to unmarshal into Key (proxy)`)
-	}
-
-	if len(proxy.Keys) == 0 {
-		k, err := ParseKey(data)
-		if err != nil {
-			return errors.Wrap(err, `failed to unmarshal key from JSON headers`)
-		}
-		s.Keys = append(s.Keys, k)
-	} else {
-		for i, buf := range proxy.Keys {
-			k, err := ParseKey([]byte(buf))
-			if err != nil {
-				return errors.Wrapf(err, `failed to unmarshal key #%d (total %d) from multi-key JWK set`, i+1, len(proxy.Keys))
-			}
-			s.Keys = append(s.Keys, k)
-		}
-	}
-	return nil
-}
-
 // Parse parses JWK from the incoming io.Reader. This function can handle
 // both single-key and multi-key formats. If you know before hand which
 // format the incoming data is in, you might want to consider using
@@ -277,40 +294,3 @@ func ParseBytes(buf []byte) (*Set, error) {
 func ParseString(s string) (*Set, error) {
 	return Parse(strings.NewReader(s))
 }
-
-// LookupKeyID looks for keys matching the given key id. Note that the
-// Set *may* contain multiple keys with the same key id
-func (s Set) LookupKeyID(kid string) []Key {
-	var keys []Key
-	for iter := s.Iterate(context.TODO()); iter.Next(context.TODO()); {
-		pair := iter.Pair()
-		key := pair.Value.(Key)
-		if key.KeyID() == kid {
-			keys = append(keys, key)
-		}
-	}
-	return keys
-}
-
-func (s *Set) Len() int {
-	return len(s.Keys)
-}
-
-func (s *Set) Iterate(ctx context.Context) KeyIterator {
-	ch := make(chan *KeyPair, s.Len())
-	go iterate(ctx, s.Keys, ch)
-	return arrayiter.New(ch)
-}
-
-func iterate(ctx context.Context, keys []Key, ch chan *KeyPair) {
-	defer close(ch)
-
-	for i, key := range keys {
-		pair := &KeyPair{Index: i, Value: key}
-		select {
-		case <-ctx.Done():
-			return
-		case ch <- pair:
-		}
-	}
-}
@@ -0,0 +1,130 @@
+package jwk_test
+
+import (
+	"crypto"
+	"encoding/json"
+	"testing"
+
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwa"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwk"
+)
+
+func TestGenerate(t *testing.T) {
+	var set jwk.Set
+	for _, kty := range []jwa.KeyType{jwa.RSA, jwa.EC, jwa.OKP, jwa.OctetSeq} {
+		key, err := jwk.Generate(kty)
+		if err != nil {
+			t.Fatalf("failed to generate %s key: %s", kty, err)
+		}
+		if key.KeyID() == "" || key.Algorithm() == "" || key.KeyUsage() != string(jwk.ForSignature) {
+			t.Errorf("%s key is missing kid, alg or use", kty)
+		}
+		set.Add(key)
+
+		if kty == jwa.OctetSeq {
+			continue
+		}
+
+		buf, err := jwk.EncodePEM(key)
+		if err != nil {
+			t.Fatalf("failed to encode %s key as PEM: %s", kty, err)
+		}
+		if _, err := jwk.ParseKeyPEM(buf); err != nil {
+			t.Errorf("failed to parse %s key from PEM: %s", kty, err)
+		}
+	}
+
+	pubset, err := set.PublicSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pubset.Len() != 3 {
+		t.Fatalf("expected 3 public keys, got %d", pubset.Len())
+	}
+
+	buf, err := json.Marshal(pubset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := jwk.ParseBytes(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range parsed.Keys() {
+		if _, ok := key.Get("d"); ok {
+			t.Errorf("public set contains private parameters for key %s", key.KeyID())
+		}
+	}
+
+	again, err := json.Marshal(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != string(again) {
+		t.Errorf("expected deterministic output, got %s and %s", buf, again)
+	}
+}
+
+func TestSet(t *testing.T) {
+	key1, err := jwk.Generate(jwa.OctetSeq, jwk.WithKeyID("one"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := jwk.Generate(jwa.OctetSeq, jwk.WithKeyID("two"), jwk.WithAlgorithm(jwa.HS512))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := jwk.NewSet(key1)
+	if !set.Add(key2) {
+		t.Fatal("expected key2 to be added")
+	}
+	if set.Add(key2) {
+		t.Error("expected duplicate key to be rejected")
+	}
+
+	if keys := set.LookupKeyID("two"); len(keys) != 1 || keys[0] != key2 {
+		t.Errorf("expected to find key2 by key ID, got %v", keys)
+	}
+
+	tp, err := key1.Thumbprint(crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, ok := set.LookupThumbprint(crypto.SHA256, tp); !ok || key != key1 {
+		t.Error("expected to find key1 by thumbprint")
+	}
+
+	filtered := set.Filter(func(key jwk.Key) bool {
+		return key.Algorithm() == jwa.HS512.String()
+	})
+	if filtered.Len() != 1 {
+		t.Errorf("expected 1 filtered key, got %d", filtered.Len())
+	}
+
+	buf, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := jwk.ParseBytes(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set.Merge(parsed)
+	if set.Len() != 2 {
+		t.Errorf("expected merge of identical keys to be a no-op, got %d keys", set.Len())
+	}
+
+	if !set.Remove(key1) {
+		t.Error("expected key1 to be removed")
+	}
+	if keys := set.LookupKeyID("one"); len(keys) != 0 {
+		t.Error("expected key1 to be removed from the index")
+	}
+
+	set.Merge(parsed)
+	if set.Len() != 2 || len(set.LookupKeyID("one")) != 1 {
+		t.Error("expected key1 to be merged back")
+	}
+}
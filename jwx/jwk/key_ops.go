@@ -9,6 +9,16 @@ func (ops *KeyOperationList) Get() KeyOperationList {
 	return *ops
 }
 
+// Has returns true if the list contains the given operation
+func (ops KeyOperationList) Has(op KeyOperation) bool {
+	for _, v := range ops {
+		if v == op {
+			return true
+		}
+	}
+	return false
+}
+
 func (ops *KeyOperationList) Accept(v interface{}) error {
 	switch x := v.(type) {
 	case string:
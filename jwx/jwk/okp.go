@@ -0,0 +1,123 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"fmt"
+
+	base642 "github.com/whlanuo/traefik-jwt-middleware/jwx/internal/base64"
+	blackmagic2 "github.com/whlanuo/traefik-jwt-middleware/jwx/internal/blackmagic"
+	jwa2 "github.com/whlanuo/traefik-jwt-middleware/jwx/jwa"
+
+	"github.com/whlanuo/traefik-jwt-middleware/errors"
+)
+
+func NewOKPPublicKey() OKPPublicKey {
+	return newOKPPublicKey()
+}
+
+func newOKPPublicKey() *okpPublicKey {
+	return &okpPublicKey{
+		privateParams: make(map[string]interface{}),
+	}
+}
+
+func NewOKPPrivateKey() OKPPrivateKey {
+	return newOKPPrivateKey()
+}
+
+func newOKPPrivateKey() *okpPrivateKey {
+	return &okpPrivateKey{
+		privateParams: make(map[string]interface{}),
+	}
+}
+
+// FromRaw initializes the key from an ed25519.PublicKey. Only the
+// Ed25519 curve is supported.
+func (k *okpPublicKey) FromRaw(rawKey interface{}) error {
+	switch key := rawKey.(type) {
+	case ed25519.PublicKey:
+		crv := jwa2.Ed25519
+		k.crv = &crv
+		k.x = []byte(key)
+	default:
+		return errors.Errorf(`unsupported key type for OKP public key: %T`, rawKey)
+	}
+	return nil
+}
+
+// FromRaw initializes the key from an ed25519.PrivateKey. Only the
+// Ed25519 curve is supported.
+func (k *okpPrivateKey) FromRaw(rawKey interface{}) error {
+	switch key := rawKey.(type) {
+	case ed25519.PrivateKey:
+		crv := jwa2.Ed25519
+		k.crv = &crv
+		k.d = key.Seed()
+		k.x = []byte(key.Public().(ed25519.PublicKey))
+	default:
+		return errors.Errorf(`unsupported key type for OKP private key: %T`, rawKey)
+	}
+	return nil
+}
+
+// Raw returns the ed25519.PublicKey represented by this JWK
+func (k *okpPublicKey) Raw(v interface{}) error {
+	if crv := k.Crv(); crv != jwa2.Ed25519 {
+		return errors.Errorf(`unsupported curve for OKP public key: %s`, crv)
+	}
+
+	if len(k.x) != ed25519.PublicKeySize {
+		return errors.Errorf(`invalid Ed25519 public key size: %d`, len(k.x))
+	}
+	return blackmagic2.AssignIfCompatible(v, ed25519.PublicKey(k.x))
+}
+
+// Raw returns the ed25519.PrivateKey represented by this JWK
+func (k *okpPrivateKey) Raw(v interface{}) error {
+	if crv := k.Crv(); crv != jwa2.Ed25519 {
+		return errors.Errorf(`unsupported curve for OKP private key: %s`, crv)
+	}
+
+	if len(k.d) != ed25519.SeedSize {
+		return errors.Errorf(`invalid Ed25519 seed size: %d`, len(k.d))
+	}
+	return blackmagic2.AssignIfCompatible(v, ed25519.NewKeyFromSeed(k.d))
+}
+
+func (k okpPrivateKey) PublicKey() (OKPPublicKey, error) {
+	var privk ed25519.PrivateKey
+	if err := k.Raw(&privk); err != nil {
+		return nil, errors.Wrap(err, `failed to materialize OKP private key`)
+	}
+
+	newKey := NewOKPPublicKey()
+	if err := newKey.FromRaw(privk.Public()); err != nil {
+		return nil, errors.Wrap(err, `failed to initialize OKPPublicKey`)
+	}
+	return newKey, nil
+}
+
+// Thumbprint returns the JWK thumbprint using the indicated
+// hashing algorithm, according to RFC 7638
+func (k okpPrivateKey) Thumbprint(hash crypto.Hash) ([]byte, error) {
+	return okpThumbprint(hash, k.Crv(), k.x)
+}
+
+func (k okpPublicKey) Thumbprint(hash crypto.Hash) ([]byte, error) {
+	return okpThumbprint(hash, k.Crv(), k.x)
+}
+
+func okpThumbprint(hash crypto.Hash, crv jwa2.EllipticCurveAlgorithm, x []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `{"crv":"%s","kty":"OKP","x":"`, crv)
+	buf.WriteString(base642.EncodeToString(x))
+	buf.WriteString(`"}`)
+
+	h := hash.New()
+	if _, err := buf.WriteTo(h); err != nil {
+		return nil, errors.Wrap(err, "failed to write okpThumbprint")
+	}
+	return h.Sum(nil), nil
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwa"
 	"github.com/whlanuo/traefik-jwt-middleware/option"
 )
 
@@ -15,6 +16,11 @@ type identThumbprintHash struct{}
 type identRefreshInterval struct{}
 type identMinRefreshInterval struct{}
 type identRefreshBackoff struct{}
+type identKeySize struct{}
+type identCurve struct{}
+type identKeyID struct{}
+type identKeyUsage struct{}
+type identAlgorithm struct{}
 
 // WithHTTPClient allows users to specify the "net/http".Client object that
 // is used when fetching *jwk.Set objects.
@@ -75,3 +81,32 @@ func WithMinRefreshInterval(d time.Duration) AutoRefreshOption {
 		option.New(identMinRefreshInterval{}, d),
 	}
 }
+
+// WithKeySize specifies the size of the key generated by jwk.Generate,
+// in bits. It applies to RSA and symmetric keys.
+func WithKeySize(bits int) Option {
+	return option.New(identKeySize{}, bits)
+}
+
+// WithCurve specifies the curve used by jwk.Generate for EC and OKP keys
+func WithCurve(crv jwa.EllipticCurveAlgorithm) Option {
+	return option.New(identCurve{}, crv)
+}
+
+// WithKeyID specifies the "kid" of the key generated by jwk.Generate.
+// If unspecified, the RFC 7638 thumbprint of the key is used.
+func WithKeyID(kid string) Option {
+	return option.New(identKeyID{}, kid)
+}
+
+// WithKeyUsage specifies the "use" of the key generated by jwk.Generate.
+// If unspecified, the key is generated for signatures.
+func WithKeyUsage(use KeyUsageType) Option {
+	return option.New(identKeyUsage{}, use)
+}
+
+// WithAlgorithm specifies the "alg" of the key generated by jwk.Generate.
+// If unspecified, a default algorithm suitable for the key type is used.
+func WithAlgorithm(alg jwa.SignatureAlgorithm) Option {
+	return option.New(identAlgorithm{}, alg)
+}
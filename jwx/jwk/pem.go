@@ -0,0 +1,196 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/whlanuo/traefik-jwt-middleware/errors"
+	base642 "github.com/whlanuo/traefik-jwt-middleware/jwx/internal/base64"
+)
+
+// PEM block types recognized by ParsePEM and produced by EncodePEM
+const (
+	pemTypeCertificate   = "CERTIFICATE"
+	pemTypeECPrivateKey  = "EC PRIVATE KEY"
+	pemTypePublicKey     = "PUBLIC KEY"
+	pemTypePrivateKey    = "PRIVATE KEY"
+	pemTypeRSAPublicKey  = "RSA PUBLIC KEY"
+	pemTypeRSAPrivateKey = "RSA PRIVATE KEY"
+)
+
+// ParsePEM parses all PEM blocks in the given buffer, and returns
+// a *jwk.Set containing one key per block.
+//
+// The following block types are supported:
+//
+// * "PUBLIC KEY" (PKIX)
+// * "RSA PUBLIC KEY" (PKCS#1)
+// * "RSA PRIVATE KEY" (PKCS#1)
+// * "PRIVATE KEY" (PKCS#8)
+// * "EC PRIVATE KEY" (SEC 1)
+// * "CERTIFICATE" (X.509)
+//
+// For certificates, the public key of the certificate is used, and the
+// certificate itself is stored in the "x5c" field of the resulting key.
+func ParsePEM(src []byte) (*Set, error) {
+	var s Set
+	for rest := src; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		key, err := parsePEMBlock(block)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to parse PEM block #%d`, s.Len()+1)
+		}
+		s.add(key)
+	}
+
+	if s.Len() == 0 {
+		return nil, errors.New(`failed to find any PEM blocks`)
+	}
+	return &s, nil
+}
+
+// ParseKeyPEM parses a single PEM encoded key. Unlike ParsePEM, this
+// function will report failure if the buffer contains more than one key.
+func ParseKeyPEM(src []byte) (Key, error) {
+	set, err := ParsePEM(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if set.Len() != 1 {
+		return nil, errors.Errorf(`expected exactly one PEM block, found %d`, set.Len())
+	}
+	key, _ := set.Get(0)
+	return key, nil
+}
+
+func parsePEMBlock(block *pem.Block) (Key, error) {
+	switch block.Type {
+	case pemTypeCertificate:
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to parse certificate`)
+		}
+
+		key, err := New(cert.PublicKey)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to create key from certificate`)
+		}
+
+		if err := key.Set(X509CertChainKey, []string{base642.EncodeToStringStd(cert.Raw)}); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %s`, X509CertChainKey)
+		}
+		return key, nil
+	case pemTypeRSAPublicKey:
+		raw, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to parse PKCS#1 public key`)
+		}
+		return New(raw)
+	case pemTypeRSAPrivateKey:
+		raw, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to parse PKCS#1 private key`)
+		}
+		return New(raw)
+	case pemTypeECPrivateKey:
+		raw, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to parse SEC 1 private key`)
+		}
+		return New(raw)
+	case pemTypePublicKey, pemTypePrivateKey:
+		return ParseDER(block.Bytes)
+	default:
+		return nil, errors.Errorf(`unsupported PEM block type %s`, block.Type)
+	}
+}
+
+// ParseDER parses a single DER encoded key. The formats PKIX, PKCS#8,
+// PKCS#1 and SEC 1 as well as X.509 certificates are tried in that order.
+func ParseDER(src []byte) (Key, error) {
+	if raw, err := x509.ParsePKIXPublicKey(src); err == nil {
+		return New(raw)
+	}
+
+	if raw, err := x509.ParsePKCS8PrivateKey(src); err == nil {
+		return New(raw)
+	}
+
+	if raw, err := x509.ParsePKCS1PublicKey(src); err == nil {
+		return New(raw)
+	}
+
+	if raw, err := x509.ParsePKCS1PrivateKey(src); err == nil {
+		return New(raw)
+	}
+
+	if raw, err := x509.ParseECPrivateKey(src); err == nil {
+		return New(raw)
+	}
+
+	if _, err := x509.ParseCertificate(src); err == nil {
+		return parsePEMBlock(&pem.Block{Type: pemTypeCertificate, Bytes: src})
+	}
+
+	return nil, errors.New(`failed to parse DER encoded key: unknown format`)
+}
+
+// EncodeDER encodes the given key in DER format. Public keys are encoded
+// in PKIX format, and private keys are encoded in PKCS#8 format.
+//
+// Symmetric keys cannot be encoded.
+func EncodeDER(key Key) ([]byte, error) {
+	_, buf, err := encodeDER(key)
+	return buf, err
+}
+
+// EncodePEM encodes the given key in PEM format. Public keys are encoded
+// as "PUBLIC KEY" blocks, and private keys are encoded as "PRIVATE KEY"
+// blocks.
+//
+// Symmetric keys cannot be encoded.
+func EncodePEM(key Key) ([]byte, error) {
+	typ, buf, err := encodeDER(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: buf}), nil
+}
+
+func encodeDER(key Key) (string, []byte, error) {
+	if key == nil {
+		return "", nil, errors.New(`non-nil key required`)
+	}
+
+	var raw interface{}
+	if err := key.Raw(&raw); err != nil {
+		return "", nil, errors.Wrap(err, `failed to materialize key`)
+	}
+
+	switch raw.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		buf, err := x509.MarshalPKCS8PrivateKey(raw)
+		if err != nil {
+			return "", nil, errors.Wrap(err, `failed to marshal PKCS#8 private key`)
+		}
+		return pemTypePrivateKey, buf, nil
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		buf, err := x509.MarshalPKIXPublicKey(raw)
+		if err != nil {
+			return "", nil, errors.Wrap(err, `failed to marshal PKIX public key`)
+		}
+		return pemTypePublicKey, buf, nil
+	default:
+		return "", nil, errors.Errorf(`unsupported key type for DER encoding: %T`, raw)
+	}
+}
@@ -0,0 +1,169 @@
+package jwk_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwa"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwk"
+)
+
+func TestParsePEM(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &ecKey.PublicKey, ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	marshal := func(der []byte, err error) []byte {
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+
+	testcases := []struct {
+		Name     string
+		Type     string
+		DER      []byte
+		KeyType  jwa.KeyType
+		Expected interface{}
+	}{
+		{Name: "PKCS#1 RSA private key", Type: "RSA PRIVATE KEY", DER: x509.MarshalPKCS1PrivateKey(rsaKey), KeyType: jwa.RSA, Expected: rsaKey},
+		{Name: "PKCS#1 RSA public key", Type: "RSA PUBLIC KEY", DER: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey), KeyType: jwa.RSA, Expected: &rsaKey.PublicKey},
+		{Name: "PKIX RSA public key", Type: "PUBLIC KEY", DER: marshal(x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)), KeyType: jwa.RSA, Expected: &rsaKey.PublicKey},
+		{Name: "PKIX EC public key", Type: "PUBLIC KEY", DER: marshal(x509.MarshalPKIXPublicKey(&ecKey.PublicKey)), KeyType: jwa.EC, Expected: &ecKey.PublicKey},
+		{Name: "PKIX Ed25519 public key", Type: "PUBLIC KEY", DER: marshal(x509.MarshalPKIXPublicKey(edPublic)), KeyType: jwa.OKP, Expected: edPublic},
+		{Name: "PKCS#8 RSA private key", Type: "PRIVATE KEY", DER: marshal(x509.MarshalPKCS8PrivateKey(rsaKey)), KeyType: jwa.RSA, Expected: rsaKey},
+		{Name: "PKCS#8 EC private key", Type: "PRIVATE KEY", DER: marshal(x509.MarshalPKCS8PrivateKey(ecKey)), KeyType: jwa.EC, Expected: ecKey},
+		{Name: "PKCS#8 Ed25519 private key", Type: "PRIVATE KEY", DER: marshal(x509.MarshalPKCS8PrivateKey(edPrivate)), KeyType: jwa.OKP, Expected: edPrivate},
+		{Name: "SEC 1 EC private key", Type: "EC PRIVATE KEY", DER: marshal(x509.MarshalECPrivateKey(ecKey)), KeyType: jwa.EC, Expected: ecKey},
+		{Name: "certificate", Type: "CERTIFICATE", DER: cert, KeyType: jwa.EC, Expected: &ecKey.PublicKey},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			check := func(key jwk.Key) {
+				if key.KeyType() != tc.KeyType {
+					t.Errorf("expected key type %s, got %s", tc.KeyType, key.KeyType())
+				}
+				var raw interface{}
+				if err := key.Raw(&raw); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(normalize(raw), normalize(tc.Expected)) {
+					t.Errorf("expected key %#v, got %#v", tc.Expected, raw)
+				}
+				if _, ok := key.Get(jwk.X509CertChainKey); ok != (tc.Type == "CERTIFICATE") {
+					t.Errorf("expected %s to be set only for certificates", jwk.X509CertChainKey)
+				}
+			}
+
+			src := pem.EncodeToMemory(&pem.Block{Type: tc.Type, Bytes: tc.DER})
+			set, err := jwk.ParsePEM(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if set.Len() != 1 {
+				t.Fatalf("expected 1 key, got %d", set.Len())
+			}
+			key, _ := set.Get(0)
+			check(key)
+
+			key, err = jwk.ParseKeyPEM(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check(key)
+
+			key, err = jwk.ParseDER(tc.DER)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check(key)
+
+			if _, err := jwk.ParsePEM(pem.EncodeToMemory(&pem.Block{Type: tc.Type, Bytes: []byte("garbage")})); err == nil {
+				t.Error("expected invalid block contents to be rejected")
+			}
+		})
+	}
+
+	t.Run("multiple blocks", func(t *testing.T) {
+		var src []byte
+		for _, tc := range testcases[:2] {
+			src = append(src, pem.EncodeToMemory(&pem.Block{Type: tc.Type, Bytes: tc.DER})...)
+		}
+		set, err := jwk.ParsePEM(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if set.Len() != 2 {
+			t.Errorf("expected 2 keys, got %d", set.Len())
+		}
+		if _, err := jwk.ParseKeyPEM(src); err == nil {
+			t.Error("expected ParseKeyPEM to reject multiple blocks")
+		}
+	})
+
+	t.Run("unknown block type", func(t *testing.T) {
+		src := pem.EncodeToMemory(&pem.Block{Type: "DSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+		if _, err := jwk.ParsePEM(src); err == nil {
+			t.Error("expected unknown block type to be rejected")
+		}
+	})
+
+	t.Run("no blocks", func(t *testing.T) {
+		if _, err := jwk.ParsePEM([]byte("not PEM")); err == nil {
+			t.Error("expected input without PEM blocks to be rejected")
+		}
+	})
+
+	t.Run("unknown DER format", func(t *testing.T) {
+		if _, err := jwk.ParseDER([]byte("garbage")); err == nil {
+			t.Error("expected unknown DER format to be rejected")
+		}
+	})
+}
+
+// normalize Returns the values identifying the key, leaving out the
+// precomputed values of RSA private keys, so that keys parsed from different
+// formats can be compared
+func normalize(raw interface{}) interface{} {
+	switch key := raw.(type) {
+	case *rsa.PrivateKey:
+		return []interface{}{key.PublicKey, key.D, key.Primes}
+	case *ecdsa.PrivateKey:
+		return []interface{}{key.X, key.Y, key.D, key.Curve.Params().Name}
+	case *ecdsa.PublicKey:
+		return []interface{}{key.X, key.Y, key.Curve.Params().Name}
+	}
+	return raw
+}
@@ -0,0 +1,345 @@
+package jwk
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"sort"
+
+	"github.com/whlanuo/traefik-jwt-middleware/iter/arrayiter"
+	base642 "github.com/whlanuo/traefik-jwt-middleware/jwx/internal/base64"
+	json2 "github.com/whlanuo/traefik-jwt-middleware/jwx/internal/json"
+
+	"github.com/whlanuo/traefik-jwt-middleware/errors"
+)
+
+// NewSet creates a new *jwk.Set containing the given keys
+func NewSet(keys ...Key) *Set {
+	var s Set
+	for _, key := range keys {
+		s.add(key)
+	}
+	return &s
+}
+
+// Add adds the given key to the set. It returns false if the
+// key is already present in the set.
+func (s *Set) Add(key Key) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(key)
+}
+
+func (s *Set) add(key Key) bool {
+	if key == nil {
+		return false
+	}
+
+	for _, k := range s.keys {
+		if k == key {
+			return false
+		}
+	}
+
+	if s.index == nil {
+		s.index = make(map[string][]Key)
+	}
+	kid := key.KeyID()
+	s.keys = append(s.keys, key)
+	s.index[kid] = append(s.index[kid], key)
+	return true
+}
+
+// Remove removes the given key from the set. It returns false if
+// the key was not present in the set.
+func (s *Set) Remove(key Key) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, k := range s.keys {
+		if k != key {
+			continue
+		}
+
+		s.keys = append(s.keys[:i:i], s.keys[i+1:]...)
+
+		kid := key.KeyID()
+		indexed := s.index[kid]
+		for j, ik := range indexed {
+			if ik == key {
+				indexed = append(indexed[:j:j], indexed[j+1:]...)
+				break
+			}
+		}
+		if len(indexed) == 0 {
+			delete(s.index, kid)
+		} else {
+			s.index[kid] = indexed
+		}
+		return true
+	}
+	return false
+}
+
+// Clear removes all keys from the set
+func (s *Set) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = nil
+	s.index = nil
+}
+
+// Get returns the key at the given index
+func (s *Set) Get(i int) (Key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if i < 0 || i >= len(s.keys) {
+		return nil, false
+	}
+	return s.keys[i], true
+}
+
+// Keys returns a snapshot of the keys in the set. Modifying the
+// returned slice does not affect the set.
+func (s *Set) Keys() []Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]Key, len(s.keys))
+	copy(keys, s.keys)
+	return keys
+}
+
+// LookupKeyID looks for keys matching the given key id. Note that the
+// Set *may* contain multiple keys with the same key id
+func (s *Set) LookupKeyID(kid string) []Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	indexed := s.index[kid]
+	if len(indexed) == 0 {
+		return nil
+	}
+
+	keys := make([]Key, len(indexed))
+	copy(keys, indexed)
+	return keys
+}
+
+// LookupThumbprint looks for the key whose RFC 7638 thumbprint, computed
+// using the given hash, matches the given value.
+func (s *Set) LookupThumbprint(hash crypto.Hash, thumbprint []byte) (Key, bool) {
+	for _, key := range s.Keys() {
+		tp, err := key.Thumbprint(hash)
+		if err != nil {
+			continue
+		}
+		if bytes.Equal(tp, thumbprint) {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// Filter returns a new *jwk.Set containing the keys for which
+// the given function returns true
+func (s *Set) Filter(fn func(Key) bool) *Set {
+	var filtered Set
+	for _, key := range s.Keys() {
+		if fn(key) {
+			filtered.add(key)
+		}
+	}
+	return &filtered
+}
+
+// Merge adds the keys of the given set that are not yet present in this
+// set. Keys are considered to be the same if their key IDs and their
+// SHA-256 thumbprints match.
+func (s *Set) Merge(other *Set) {
+	if other == nil || other == s {
+		return
+	}
+
+	keys := other.Keys()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		if s.contains(key) {
+			continue
+		}
+		s.add(key)
+	}
+}
+
+func (s *Set) contains(key Key) bool {
+	candidates := s.index[key.KeyID()]
+	if len(candidates) == 0 {
+		return false
+	}
+
+	tp, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return false
+	}
+	for _, candidate := range candidates {
+		if candidate == key {
+			return true
+		}
+		ctp, err := candidate.Thumbprint(crypto.SHA256)
+		if err == nil && bytes.Equal(tp, ctp) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Set) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.keys)
+}
+
+func (s *Set) Iterate(ctx context.Context) KeyIterator {
+	keys := s.Keys()
+	ch := make(chan *KeyPair, len(keys))
+	go iterate(ctx, keys, ch)
+	return arrayiter.New(ch)
+}
+
+func iterate(ctx context.Context, keys []Key, ch chan *KeyPair) {
+	defer close(ch)
+
+	for i, key := range keys {
+		pair := &KeyPair{Index: i, Value: key}
+		select {
+		case <-ctx.Done():
+			return
+		case ch <- pair:
+		}
+	}
+}
+
+// PublicSet returns a new *jwk.Set containing the public counterparts
+// of the keys in this set. Private keys are converted using their
+// PublicKey() method, while keeping their standard fields such as
+// "kid", "use" and "alg". Public keys are copied as is, and symmetric
+// keys, which do not have a public counterpart, are omitted.
+func (s *Set) PublicSet() (*Set, error) {
+	var ps Set
+	for i, key := range s.Keys() {
+		pubkey, err := publicKey(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to get public key for key #%d`, i+1)
+		}
+		if pubkey == nil {
+			continue
+		}
+		ps.add(pubkey)
+	}
+	return &ps, nil
+}
+
+func publicKey(key Key) (Key, error) {
+	var pubkey Key
+	var err error
+	switch key := key.(type) {
+	case RSAPrivateKey:
+		pubkey, err = key.PublicKey()
+	case ECDSAPrivateKey:
+		pubkey, err = key.PublicKey()
+	case OKPPrivateKey:
+		pubkey, err = key.PublicKey()
+	case SymmetricKey:
+		return nil, nil
+	default:
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{AlgorithmKey, KeyIDKey, KeyUsageKey, X509URLKey, X509CertThumbprintKey, X509CertThumbprintS256Key} {
+		if v, ok := key.Get(name); ok {
+			if err := pubkey.Set(name, v); err != nil {
+				return nil, errors.Wrapf(err, `failed to set %s`, name)
+			}
+		}
+	}
+
+	if ops := key.KeyOps(); len(ops) > 0 {
+		var pubops []KeyOperation
+		for _, op := range ops {
+			switch op {
+			case KeyOpSign:
+				pubops = append(pubops, KeyOpVerify)
+			case KeyOpVerify, KeyOpEncrypt, KeyOpWrapKey:
+				pubops = append(pubops, op)
+			}
+		}
+		if len(pubops) > 0 {
+			if err := pubkey.Set(KeyOpsKey, pubops); err != nil {
+				return nil, errors.Wrapf(err, `failed to set %s`, KeyOpsKey)
+			}
+		}
+	}
+
+	if certs := key.X509CertChain(); len(certs) > 0 {
+		encoded := make([]string, len(certs))
+		for i, cert := range certs {
+			encoded[i] = base642.EncodeToStringStd(cert.Raw)
+		}
+		if err := pubkey.Set(X509CertChainKey, encoded); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %s`, X509CertChainKey)
+		}
+	}
+	return pubkey, nil
+}
+
+func (s *Set) UnmarshalJSON(data []byte) error {
+	var proxy struct {
+		Keys []json2.RawMessage `json:"keys"`
+	}
+
+	if err := json2.Unmarshal(data, &proxy); err != nil {
+		return errors.Wrap(err, `failed to unmarshal into Key (proxy)`)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(proxy.Keys) == 0 {
+		k, err := ParseKey(data)
+		if err != nil {
+			return errors.Wrap(err, `failed to unmarshal key from JSON headers`)
+		}
+		s.add(k)
+	} else {
+		for i, buf := range proxy.Keys {
+			k, err := ParseKey([]byte(buf))
+			if err != nil {
+				return errors.Wrapf(err, `failed to unmarshal key #%d (total %d) from multi-key JWK set`, i+1, len(proxy.Keys))
+			}
+			s.add(k)
+		}
+	}
+	return nil
+}
+
+// MarshalJSON serializes the set in the `{"keys":[...]}` format.
+// Keys are ordered by their key ID, so that the same set of keys
+// always produces the same output.
+func (s *Set) MarshalJSON() ([]byte, error) {
+	keys := s.Keys()
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].KeyID() < keys[j].KeyID()
+	})
+
+	var proxy struct {
+		Keys []Key `json:"keys"`
+	}
+	proxy.Keys = keys
+	return json2.Marshal(proxy)
+}
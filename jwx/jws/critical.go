@@ -0,0 +1,94 @@
+package jws
+
+import (
+	"sync"
+
+	"github.com/whlanuo/traefik-jwt-middleware/errors"
+)
+
+// standardHeaders are the header parameters defined in RFC 7515, which
+// must not be listed in the "crit" header
+var standardHeaders = map[string]struct{}{
+	AlgorithmKey:              {},
+	ContentTypeKey:            {},
+	CriticalKey:               {},
+	JWKKey:                    {},
+	JWKSetURLKey:              {},
+	KeyIDKey:                  {},
+	TypeKey:                   {},
+	X509CertChainKey:          {},
+	X509CertThumbprintKey:     {},
+	X509CertThumbprintS256Key: {},
+	X509URLKey:                {},
+}
+
+var criticalHeadersMu sync.RWMutex
+
+// criticalHeaders are the header parameters that may be listed in the
+// "crit" header, because they are understood either by this package or by
+// the application
+var criticalHeaders = map[string]struct{}{
+	B64Key: {},
+}
+
+// RegisterCriticalHeader registers a header parameter as understood by the
+// application, so that messages listing it in their "crit" header are
+// accepted by Verify, VerifyDetached and jwt.Parse. The application is
+// responsible for processing the header parameter after verification.
+//
+// Header parameters defined in RFC 7515 cannot be registered, as they must
+// never be listed in the "crit" header.
+func RegisterCriticalHeader(name string) error {
+	if _, ok := standardHeaders[name]; ok {
+		return errors.Errorf(`standard header %#v cannot be critical`, name)
+	}
+
+	criticalHeadersMu.Lock()
+	defer criticalHeadersMu.Unlock()
+	criticalHeaders[name] = struct{}{}
+	return nil
+}
+
+// UnregisterCriticalHeader removes a header parameter previously registered
+// via RegisterCriticalHeader
+func UnregisterCriticalHeader(name string) {
+	if name == B64Key {
+		return
+	}
+
+	criticalHeadersMu.Lock()
+	defer criticalHeadersMu.Unlock()
+	delete(criticalHeaders, name)
+}
+
+// checkCritical makes sure that all header parameters listed in "crit" are
+// understood and present, as required by RFC 7515 section 4.1.11. The
+// header parameters given in understood are accepted in addition to the
+// registered ones.
+func checkCritical(hdrs Headers, understood []string) error {
+	for _, name := range hdrs.Critical() {
+		if _, ok := standardHeaders[name]; ok {
+			return errors.Errorf(`standard header %#v must not be critical`, name)
+		}
+		if !isUnderstood(name, understood) {
+			return errors.Errorf(`unsupported critical header %#v`, name)
+		}
+		if _, ok := hdrs.Get(name); !ok {
+			return errors.Errorf(`critical header %#v is missing`, name)
+		}
+	}
+	return nil
+}
+
+func isUnderstood(name string, understood []string) bool {
+	for _, u := range understood {
+		if u == name {
+			return true
+		}
+	}
+
+	criticalHeadersMu.RLock()
+	defer criticalHeadersMu.RUnlock()
+	_, ok := criticalHeaders[name]
+	return ok
+}
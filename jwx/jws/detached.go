@@ -0,0 +1,147 @@
+package jws
+
+import (
+	"bytes"
+	"encoding/base64"
+
+	json2 "github.com/whlanuo/traefik-jwt-middleware/jwx/internal/json"
+	pool2 "github.com/whlanuo/traefik-jwt-middleware/jwx/internal/pool"
+	jwa2 "github.com/whlanuo/traefik-jwt-middleware/jwx/jwa"
+	verify2 "github.com/whlanuo/traefik-jwt-middleware/jwx/jws/verify"
+
+	"github.com/whlanuo/traefik-jwt-middleware/errors"
+)
+
+// B64Key is the header parameter defined in RFC 7797. When set to false,
+// the payload is signed as is instead of being base64url encoded first.
+const B64Key = "b64"
+
+// SignDetached generates a signature for the given payload like Sign does,
+// but omits the payload from the result, which is serialized as
+// `header..signature` (RFC 7515 Appendix F). The recipient must obtain the
+// payload by other means, and verify it using VerifyDetached.
+//
+// Combined with the "b64" header set to false, this creates a JWS over the
+// payload as is, as commonly used to sign HTTP bodies.
+func SignDetached(payload []byte, alg jwa2.SignatureAlgorithm, key interface{}, options ...Option) ([]byte, error) {
+	return signCompact(payload, alg, key, true, options...)
+}
+
+// VerifyDetached verifies a JWS in compact serialization format whose
+// payload was detached, using the given payload. The payload is base64url
+// encoded before verification, unless the "b64" header is false.
+//
+// Critical headers other than "b64" must be registered via
+// RegisterCriticalHeader, or passed via WithCriticalHeaders.
+func VerifyDetached(payload, buf []byte, alg jwa2.SignatureAlgorithm, key interface{}, options ...Option) error {
+	verifier, err := verify2.New(alg)
+	if err != nil {
+		return errors.Wrap(err, "failed to create verifier")
+	}
+
+	protected, attached, signature, err := SplitCompact(bytes.NewReader(bytes.TrimSpace(buf)))
+	if err != nil {
+		return errors.Wrap(err, `failed extract from compact serialization format`)
+	}
+	if len(attached) > 0 {
+		return errors.New(`invalid detached JWS: payload is not empty`)
+	}
+
+	hdrs, err := decodeProtectedHeaders(protected)
+	if err != nil {
+		return err
+	}
+	encoded, err := checkHeaders(hdrs, options...)
+	if err != nil {
+		return err
+	}
+
+	verifyBuf := pool2.GetBytesBuffer()
+	defer pool2.ReleaseBytesBuffer(verifyBuf)
+
+	verifyBuf.Write(protected)
+	verifyBuf.WriteByte('.')
+	if encoded {
+		enc := base64.NewEncoder(base64.RawURLEncoding, verifyBuf)
+		if _, err := enc.Write(payload); err != nil {
+			return errors.Wrap(err, `failed to write payload as base64`)
+		}
+		if err := enc.Close(); err != nil {
+			return errors.Wrap(err, `failed to finalize writing payload as base64`)
+		}
+	} else {
+		verifyBuf.Write(payload)
+	}
+
+	decodedSignature := make([]byte, base64.RawURLEncoding.DecodedLen(len(signature)))
+	if _, err := base64.RawURLEncoding.Decode(decodedSignature, signature); err != nil {
+		return errors.Wrap(err, `failed to decode signature`)
+	}
+	if err := verifier.Verify(verifyBuf.Bytes(), decodedSignature, key); err != nil {
+		return errors.Wrap(err, `failed to verify message`)
+	}
+	return nil
+}
+
+func decodeProtectedHeaders(protected []byte) (Headers, error) {
+	decoded := make([]byte, base64.RawURLEncoding.DecodedLen(len(protected)))
+	if _, err := base64.RawURLEncoding.Decode(decoded, protected); err != nil {
+		return nil, errors.Wrap(err, `failed to decode headers`)
+	}
+
+	hdrs := NewHeaders()
+	if err := json2.Unmarshal(decoded, hdrs); err != nil {
+		return nil, errors.Wrap(err, `failed to parse JOSE headers`)
+	}
+	return hdrs, nil
+}
+
+// checkHeaders checks the protected headers of a JWS before verification,
+// and reports whether its payload is base64url encoded
+func checkHeaders(hdrs Headers, options ...Option) (bool, error) {
+	var understood []string
+	for _, o := range options {
+		switch o.Ident() {
+		case identCriticalHeaders{}:
+			understood = append(understood, o.Value().([]string)...)
+		}
+	}
+
+	if err := checkCritical(hdrs, understood); err != nil {
+		return false, errors.Wrap(err, `invalid JOSE headers`)
+	}
+
+	encoded, err := payloadEncoded(hdrs)
+	if err != nil {
+		return false, errors.Wrap(err, `invalid JOSE headers`)
+	}
+	return encoded, nil
+}
+
+// payloadEncoded reports whether the payload is base64url encoded, according
+// to the "b64" header. As required by RFC 7797, a "b64" header set to false
+// must be listed in "crit".
+func payloadEncoded(hdrs Headers) (bool, error) {
+	v, ok := hdrs.Get(B64Key)
+	if !ok {
+		return true, nil
+	}
+
+	encoded, ok := v.(bool)
+	if !ok {
+		return false, errors.Errorf(`invalid value for %s header: %T`, B64Key, v)
+	}
+	if !encoded && !isCritical(hdrs, B64Key) {
+		return false, errors.Errorf(`%s header must be listed in %s`, B64Key, CriticalKey)
+	}
+	return encoded, nil
+}
+
+func isCritical(hdrs Headers, name string) bool {
+	for _, critical := range hdrs.Critical() {
+		if critical == name {
+			return true
+		}
+	}
+	return false
+}
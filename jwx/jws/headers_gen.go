@@ -359,7 +359,7 @@ func (h *stdHeaders) UnmarshalJSON(buf []byte) error {
 		if err != nil {
 			return errors.Wrap(err, `failed to parse jwk field`)
 		}
-		h.jwk = set.Keys[0]
+		h.jwk, _ = set.Get(0)
 	}
 	h.algorithm = proxy.Xalgorithm
 	h.contentType = proxy.XcontentType
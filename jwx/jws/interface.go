@@ -63,11 +63,23 @@ func (f JWKAcceptFunc) Accept(key jwk2.Key) bool {
 }
 
 // DefaultJWKAcceptor is the default acceptor that is used
-// in functions like VerifyWithJWKSet
+// in functions like VerifyWithJWKSet. It rejects keys whose "use"
+// is anything other than "sig", and keys whose "key_ops" does not
+// include "verify". Keys that specify neither are accepted.
 var DefaultJWKAcceptor = JWKAcceptFunc(func(key jwk2.Key) bool {
-	if u := key.KeyUsage(); u != "" && u != "enc" && u != "sig" {
+	if u := key.KeyUsage(); u != "" && u != jwk2.ForSignature.String() {
 		return false
 	}
+	if ops := key.KeyOps(); len(ops) > 0 && !ops.Has(jwk2.KeyOpVerify) {
+		return false
+	}
+	return true
+})
+
+// PermissiveJWKAcceptor accepts any key regardless of its "use" and
+// "key_ops" fields. It can be used to opt out of the checks performed
+// by DefaultJWKAcceptor.
+var PermissiveJWKAcceptor = JWKAcceptFunc(func(key jwk2.Key) bool {
 	return true
 })
 
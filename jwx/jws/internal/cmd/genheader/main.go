@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+func main() {
+	if err := _main(); err != nil {
+		log.Printf("%s", err)
+		os.Exit(1)
+	}
+}
+
+type headerField struct {
+	name      string
+	method    string
+	typ       string
+	key       string
+	comment   string
+	hasAccept bool
+	noDeref   bool
+	jsonTag   string
+}
+
+func (f headerField) IsPointer() bool {
+	return !f.noDeref
+}
+
+func (f headerField) PointerElem() string {
+	return f.typ
+}
+
+func fieldStorageType(f headerField) string {
+	if f.IsPointer() {
+		return "*" + f.typ
+	}
+	return f.typ
+}
+
+func fieldProxyType(f headerField) string {
+	if f.name == "jwk" {
+		return "json2.RawMessage"
+	}
+	return fieldStorageType(f)
+}
+
+func fieldDeref(f headerField) string {
+	if f.IsPointer() {
+		return "*(h." + f.name + ")"
+	}
+	return "h." + f.name
+}
+
+func _main() error {
+	fields := []headerField{
+		{
+			name:      "algorithm",
+			method:    "Algorithm",
+			typ:       "jwa2.SignatureAlgorithm",
+			key:       "alg",
+			comment:   "https://tools.ietf.org/html/rfc7515#section-4.1.1",
+			hasAccept: true,
+			jsonTag:   "`json:\"alg,omitempty\"`",
+		},
+		{
+			name:    "contentType",
+			method:  "ContentType",
+			typ:     "string",
+			key:     "cty",
+			comment: "https://tools.ietf.org/html/rfc7515#section-4.1.10",
+			jsonTag: "`json:\"cty,omitempty\"`",
+		},
+		{
+			name:    "critical",
+			method:  "Critical",
+			typ:     "[]string",
+			key:     "crit",
+			comment: "https://tools.ietf.org/html/rfc7515#section-4.1.11",
+			noDeref: true,
+			jsonTag: "`json:\"crit,omitempty\"`",
+		},
+		{
+			name:    "jwk",
+			method:  "JWK",
+			typ:     "jwk2.Key",
+			key:     "jwk",
+			comment: "https://tools.ietf.org/html/rfc7515#section-4.1.3",
+			noDeref: true,
+			jsonTag: "`json:\"jwk,omitempty\"`",
+		},
+		{
+			name:    "jwkSetURL",
+			method:  "JWKSetURL",
+			typ:     "string",
+			key:     "jku",
+			comment: "https://tools.ietf.org/html/rfc7515#section-4.1.2",
+			jsonTag: "`json:\"jku,omitempty\"`",
+		},
+		{
+			name:    "keyID",
+			method:  "KeyID",
+			typ:     "string",
+			key:     "kid",
+			comment: "https://tools.ietf.org/html/rfc7515#section-4.1.4",
+			jsonTag: "`json:\"kid,omitempty\"`",
+		},
+		{
+			name:    "typ",
+			method:  "Type",
+			typ:     "string",
+			key:     "typ",
+			comment: "https://tools.ietf.org/html/rfc7515#section-4.1.9",
+			jsonTag: "`json:\"typ,omitempty\"`",
+		},
+		{
+			name:    "x509CertChain",
+			method:  "X509CertChain",
+			typ:     "[]string",
+			key:     "x5c",
+			comment: "https://tools.ietf.org/html/rfc7515#section-4.1.6",
+			noDeref: true,
+			jsonTag: "`json:\"x5c,omitempty\"`",
+		},
+		{
+			name:    "x509CertThumbprint",
+			method:  "X509CertThumbprint",
+			typ:     "string",
+			key:     "x5t",
+			comment: "https://tools.ietf.org/html/rfc7515#section-4.1.7",
+			jsonTag: "`json:\"x5t,omitempty\"`",
+		},
+		{
+			name:    "x509CertThumbprintS256",
+			method:  "X509CertThumbprintS256",
+			typ:     "string",
+			key:     "x5t#S256",
+			comment: "https://tools.ietf.org/html/rfc7515#section-4.1.8",
+			jsonTag: "`json:\"x5t#S256,omitempty\"`",
+		},
+		{
+			name:    "x509URL",
+			method:  "X509URL",
+			typ:     "string",
+			key:     "x5u",
+			comment: "https://tools.ietf.org/html/rfc7515#section-4.1.5",
+			jsonTag: "`json:\"x5u,omitempty\"`",
+		},
+	}
+
+	if err := generateHeaders(fields); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func generateHeaders(fields []headerField) error {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "\n// This file is auto-generated. DO NOT EDIT")
+	fmt.Fprintf(&buf, "\npackage jws")
+	fmt.Fprintf(&buf, "\n\nimport (")
+	for _, pkg := range []string{"bytes", "context", "fmt", "json2 github.com/whlanuo/traefik-jwt-middleware/jwx/internal/json", "jwa2 github.com/whlanuo/traefik-jwt-middleware/jwx/jwa", "jwk2 github.com/whlanuo/traefik-jwt-middleware/jwx/jwk", "sort", "strconv"} {
+		if alias, path, ok := splitAlias(pkg); ok {
+			fmt.Fprintf(&buf, "\n%s %q", alias, path)
+		} else {
+			fmt.Fprintf(&buf, "\n%q", pkg)
+		}
+	}
+	fmt.Fprintf(&buf, "\n\n\"github.com/whlanuo/traefik-jwt-middleware/errors\"")
+	fmt.Fprintf(&buf, "\n)")
+
+	fmt.Fprintf(&buf, "\n\nconst (")
+	for _, f := range fields {
+		fmt.Fprintf(&buf, "\n%sKey = %q", f.method, f.key)
+	}
+	fmt.Fprintf(&buf, "\n)") // end const
+
+	fmt.Fprintf(&buf, "\n\n// Headers describe a standard Header set.")
+	fmt.Fprintf(&buf, "\ntype Headers interface {")
+	for _, f := range fields {
+		fmt.Fprintf(&buf, "\n%s() %s", f.method, f.typ)
+	}
+	fmt.Fprintf(&buf, "\nIterate(ctx context.Context) Iterator")
+	fmt.Fprintf(&buf, "\nWalk(ctx context.Context, v Visitor) error")
+	fmt.Fprintf(&buf, "\nAsMap(ctx context.Context) (map[string]interface{}, error)")
+	fmt.Fprintf(&buf, "\nGet(string) (interface{}, bool)")
+	fmt.Fprintf(&buf, "\nSet(string, interface{}) error")
+	fmt.Fprintf(&buf, "\n\n// PrivateParams returns the non-standard elements in the source structure")
+	fmt.Fprintf(&buf, "\n// WARNING: DO NOT USE PrivateParams() IF YOU HAVE CONCURRENT CODE ACCESSING THEM.")
+	fmt.Fprintf(&buf, "\n// Use AsMap() to get a copy of the entire header instead")
+	fmt.Fprintf(&buf, "\nPrivateParams() map[string]interface{}")
+	fmt.Fprintf(&buf, "\n}")
+
+	fmt.Fprintf(&buf, "\n\ntype stdHeaders struct {")
+	for _, f := range fields {
+		fmt.Fprintf(&buf, "\n%s %s // %s", f.name, fieldStorageType(f), f.comment)
+	}
+	fmt.Fprintf(&buf, "\nprivateParams map[string]interface{}")
+	fmt.Fprintf(&buf, "\n}") // end type StandardHeaders
+
+	fmt.Fprintf(&buf, "\n\ntype standardHeadersMarshalProxy struct {")
+	for _, f := range fields {
+		fmt.Fprintf(&buf, "\nX%s %s %s", f.name, fieldProxyType(f), f.jsonTag)
+	}
+	fmt.Fprintf(&buf, "\n}")
+
+	fmt.Fprintf(&buf, "\n\nfunc NewHeaders() Headers {")
+	fmt.Fprintf(&buf, "\nreturn &stdHeaders{}")
+	fmt.Fprintf(&buf, "\n}")
+
+	for _, f := range fields {
+		fmt.Fprintf(&buf, "\n\nfunc (h *stdHeaders) %s() %s{", f.method, f.typ)
+		if f.IsPointer() {
+			fmt.Fprintf(&buf, "\nif h.%s == nil {", f.name)
+			fmt.Fprintf(&buf, "\nreturn \"\"")
+			fmt.Fprintf(&buf, "\n}")
+			fmt.Fprintf(&buf, "\nreturn *(h.%s)", f.name)
+		} else {
+			fmt.Fprintf(&buf, "\nreturn h.%s", f.name)
+		}
+		fmt.Fprintf(&buf, "\n}") // func (h *stdHeaders) %s() %s
+	}
+
+	fmt.Fprintf(&buf, "\n\nfunc (h *stdHeaders) iterate(ctx context.Context, ch chan *HeaderPair) {")
+	fmt.Fprintf(&buf, "\ndefer close(ch)")
+	fmt.Fprintf(&buf, "\nvar pairs []*HeaderPair")
+	for _, f := range fields {
+		fmt.Fprintf(&buf, "\nif h.%s != nil {", f.name)
+		fmt.Fprintf(&buf, "\npairs = append(pairs, &HeaderPair{Key: %sKey, Value: %s})", f.method, fieldDeref(f))
+		fmt.Fprintf(&buf, "\n}")
+	}
+	fmt.Fprintf(&buf, "\nfor k, v := range h.privateParams {")
+	fmt.Fprintf(&buf, "\npairs = append(pairs, &HeaderPair{Key: k, Value: v})")
+	fmt.Fprintf(&buf, "\n}")
+	fmt.Fprintf(&buf, "\nfor _, pair := range pairs {")
+	fmt.Fprintf(&buf, "\nselect {")
+	fmt.Fprintf(&buf, "\ncase <-ctx.Done():")
+	fmt.Fprintf(&buf, "\nreturn")
+	fmt.Fprintf(&buf, "\ncase ch <- pair:")
+	fmt.Fprintf(&buf, "\n}")
+	fmt.Fprintf(&buf, "\n}")
+	fmt.Fprintf(&buf, "\n}") // end of (h *stdHeaders) iterate(...)
+
+	fmt.Fprintf(&buf, "\n\nfunc (h *stdHeaders) PrivateParams() map[string]interface{} {")
+	fmt.Fprintf(&buf, "\nreturn h.privateParams")
+	fmt.Fprintf(&buf, "\n}")
+
+	fmt.Fprintf(&buf, "\n\nfunc (h *stdHeaders) Get(name string) (interface{}, bool) {")
+	fmt.Fprintf(&buf, "\nswitch name {")
+	for _, f := range fields {
+		fmt.Fprintf(&buf, "\ncase %sKey:", f.method)
+		fmt.Fprintf(&buf, "\nif h.%s == nil {", f.name)
+		fmt.Fprintf(&buf, "\nreturn nil, false")
+		fmt.Fprintf(&buf, "\n}")
+		fmt.Fprintf(&buf, "\nreturn %s, true", fieldDeref(f))
+	}
+	fmt.Fprintf(&buf, "\ndefault:")
+	fmt.Fprintf(&buf, "\nv, ok := h.privateParams[name]")
+	fmt.Fprintf(&buf, "\nreturn v, ok")
+	fmt.Fprintf(&buf, "\n}") // end switch name
+	fmt.Fprintf(&buf, "\n}") // func (h *stdHeaders) Get(name string) (interface{}, bool)
+
+	fmt.Fprintf(&buf, "\n\nfunc (h *stdHeaders) Set(name string, value interface{}) error {")
+	fmt.Fprintf(&buf, "\nswitch name {")
+	for _, f := range fields {
+		fmt.Fprintf(&buf, "\ncase %sKey:", f.method)
+		if f.hasAccept {
+			fmt.Fprintf(&buf, "\nvar acceptor %s", f.PointerElem())
+			fmt.Fprintf(&buf, "\nif err := acceptor.Accept(value); err != nil {")
+			fmt.Fprintf(&buf, "\nreturn errors.Wrapf(err, `invalid value for %%s key`, %sKey)", f.method)
+			fmt.Fprintf(&buf, "\n}") // end if err := h.%s.Accept(value)
+			fmt.Fprintf(&buf, "\nh.%s = &acceptor", f.name)
+			fmt.Fprintf(&buf, "\nreturn nil")
+		} else {
+			fmt.Fprintf(&buf, "\nif v, ok := value.(%s); ok {", f.typ)
+			if f.IsPointer() {
+				fmt.Fprintf(&buf, "\nh.%s = &v", f.name)
+			} else {
+				fmt.Fprintf(&buf, "\nh.%s = v", f.name)
+			}
+			fmt.Fprintf(&buf, "\nreturn nil")
+			fmt.Fprintf(&buf, "\n}") // end if v, ok := value.(%s)
+			fmt.Fprintf(&buf, "\nreturn errors.Errorf(`invalid value for %%s key: %%T`, %sKey, value)", f.method)
+		}
+	}
+	fmt.Fprintf(&buf, "\ndefault:")
+	fmt.Fprintf(&buf, "\nif h.privateParams == nil {")
+	fmt.Fprintf(&buf, "\nh.privateParams = map[string]interface{}{}")
+	fmt.Fprintf(&buf, "\n}") // end if h.privateParams == nil
+	fmt.Fprintf(&buf, "\nh.privateParams[name] = value")
+	fmt.Fprintf(&buf, "\n}") // end switch name
+	fmt.Fprintf(&buf, "\nreturn nil")
+	fmt.Fprintf(&buf, "\n}") // end func (h *stdHeaders) Set(name string, value interface{})
+
+	fmt.Fprintf(&buf, "\n\nfunc (h *stdHeaders) UnmarshalJSON(buf []byte) error {")
+	fmt.Fprintf(&buf, "\nvar proxy standardHeadersMarshalProxy")
+	fmt.Fprintf(&buf, "\nif err := json2.Unmarshal(buf, &proxy); err != nil {")
+	fmt.Fprintf(&buf, "\nreturn errors.Wrap(err, `failed to unmarshal headers`)")
+	fmt.Fprintf(&buf, "\n}")
+
+	fmt.Fprintf(&buf, "\n\nh.jwk = nil")
+	fmt.Fprintf(&buf, "\nif jwkField := proxy.Xjwk; len(jwkField) > 0 {")
+	fmt.Fprintf(&buf, "\nset, err := jwk2.ParseBytes([]byte(proxy.Xjwk))")
+	fmt.Fprintf(&buf, "\nif err != nil {")
+	fmt.Fprintf(&buf, "\nreturn errors.Wrap(err, `failed to parse jwk field`)")
+	fmt.Fprintf(&buf, "\n}")
+	fmt.Fprintf(&buf, "\nh.jwk, _ = set.Get(0)")
+	fmt.Fprintf(&buf, "\n}")
+
+	for _, f := range fields {
+		if f.name == "jwk" {
+			continue
+		}
+		fmt.Fprintf(&buf, "\nh.%[1]s = proxy.X%[1]s", f.name)
+	}
+
+	fmt.Fprintf(&buf, "\nvar m map[string]interface{}")
+	fmt.Fprintf(&buf, "\nif err := json2.Unmarshal(buf, &m); err != nil {")
+	fmt.Fprintf(&buf, "\nreturn errors.Wrap(err, `failed to parse privsate parameters`)")
+	fmt.Fprintf(&buf, "\n}")
+	// remove all standard keys
+	for _, f := range fields {
+		fmt.Fprintf(&buf, "\ndelete(m, %sKey)", f.method)
+	}
+
+	fmt.Fprintf(&buf, "\nh.privateParams = m")
+	fmt.Fprintf(&buf, "\nreturn nil")
+	fmt.Fprintf(&buf, "\n}")
+
+	fmt.Fprintf(&buf, "\n\nfunc (h stdHeaders) MarshalJSON() ([]byte, error) {")
+	fmt.Fprintf(&buf, "\nvar proxy standardHeadersMarshalProxy")
+	fmt.Fprintf(&buf, "\nif h.jwk != nil {")
+	fmt.Fprintf(&buf, "\njwkbuf, err := json2.Marshal(h.jwk)")
+	fmt.Fprintf(&buf, "\nif err != nil {")
+	fmt.Fprintf(&buf, "\nreturn nil, errors.Wrap(err, `failed to marshal jwk field`)")
+	fmt.Fprintf(&buf, "\n}")
+	fmt.Fprintf(&buf, "\nproxy.Xjwk = jwkbuf")
+	fmt.Fprintf(&buf, "\n}")
+
+	for _, f := range fields {
+		if f.name == "jwk" {
+			continue
+		}
+		fmt.Fprintf(&buf, "\nproxy.X%[1]s = h.%[1]s", f.name)
+	}
+
+	fmt.Fprintf(&buf, "\nvar buf bytes.Buffer")
+	fmt.Fprintf(&buf, "\nenc := json2.NewEncoder(&buf)")
+	fmt.Fprintf(&buf, "\nif err := enc.Encode(proxy); err != nil {")
+	fmt.Fprintf(&buf, "\nreturn nil, errors.Wrap(err, `failed to encode proxy to JSON`)")
+	fmt.Fprintf(&buf, "\n}")
+	fmt.Fprintf(&buf, "\nhasContent := buf.Len() > 3 // encoding/json always adds a newline, so \"{}\\n\" is the empty hash")
+	fmt.Fprintf(&buf, "\nif l := len(h.privateParams); l > 0 {")
+	fmt.Fprintf(&buf, "\nbuf.Truncate(buf.Len() - 2)")
+	fmt.Fprintf(&buf, "\nkeys := make([]string, 0, l)")
+	fmt.Fprintf(&buf, "\nfor k := range h.privateParams {")
+	fmt.Fprintf(&buf, "\nkeys = append(keys, k)")
+	fmt.Fprintf(&buf, "\n}")
+	fmt.Fprintf(&buf, "\nsort.Strings(keys)")
+	fmt.Fprintf(&buf, "\nfor i, k := range keys {")
+	fmt.Fprintf(&buf, "\nif hasContent || i > 0 {")
+	fmt.Fprintf(&buf, "\nfmt.Fprintf(&buf, `,`)")
+	fmt.Fprintf(&buf, "\n}")
+	fmt.Fprintf(&buf, "\nfmt.Fprintf(&buf, `%%s:`, strconv.Quote(k))")
+	fmt.Fprintf(&buf, "\nif err := enc.Encode(h.privateParams[k]); err != nil {")
+	fmt.Fprintf(&buf, "\nreturn nil, errors.Wrapf(err, `failed to encode private param %%s`, k)")
+	fmt.Fprintf(&buf, "\n}")
+	fmt.Fprintf(&buf, "\n}")
+	fmt.Fprintf(&buf, "\nfmt.Fprintf(&buf, `}`)")
+	fmt.Fprintf(&buf, "\n}")
+	fmt.Fprintf(&buf, "\nreturn buf.Bytes(), nil")
+	fmt.Fprintf(&buf, "\n}") // end of MarshalJSON
+
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		buf.WriteTo(log.Writer())
+		return fmt.Errorf("failed to format code: %w", err)
+	}
+
+	if err := ioutil.WriteFile("headers_gen.go", formatted, 0644); err != nil {
+		return fmt.Errorf("failed to write to headers_gen.go: %w", err)
+	}
+	return nil
+}
+
+// splitAlias Splits an "alias path" import spec
+func splitAlias(spec string) (string, string, bool) {
+	for i := 0; i < len(spec); i++ {
+		if spec[i] == ' ' {
+			return spec[:i], spec[i+1:], true
+		}
+	}
+	return "", "", false
+}
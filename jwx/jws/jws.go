@@ -77,7 +77,15 @@ func (s *payloadSigner) PublicHeader() Headers {
 // the type of key you provided, otherwise an error is returned.
 //
 // If you would like to pass custom headers, use the WithHeaders option.
+//
+// To sign with an unencoded payload as described in RFC 7797, set the
+// "b64" header to false via WithHeaders. The payload must then not contain
+// any '.' characters, unless it is detached using SignDetached.
 func Sign(payload []byte, alg jwa2.SignatureAlgorithm, key interface{}, options ...Option) ([]byte, error) {
+	return signCompact(payload, alg, key, false, options...)
+}
+
+func signCompact(payload []byte, alg jwa2.SignatureAlgorithm, key interface{}, detached bool, options ...Option) ([]byte, error) {
 	var hdrs Headers
 	for _, o := range options {
 		switch o.Ident() {
@@ -115,6 +123,21 @@ func Sign(payload []byte, alg jwa2.SignatureAlgorithm, key interface{}, options
 		return nil, errors.Wrap(err, `failed to set header`)
 	}
 
+	// "b64" must be understood by the recipient, so it is always critical
+	if v, ok := hdrs.Get(B64Key); ok && v == false && !isCritical(hdrs, B64Key) {
+		if err := hdrs.Set(CriticalKey, append(hdrs.Critical(), B64Key)); err != nil {
+			return nil, errors.Wrap(err, `failed to set header`)
+		}
+	}
+
+	encoded, err := payloadEncoded(hdrs)
+	if err != nil {
+		return nil, errors.Wrap(err, `invalid headers`)
+	}
+	if !encoded && !detached && bytes.IndexByte(payload, '.') >= 0 {
+		return nil, errors.New(`unencoded payload must not contain '.' unless detached`)
+	}
+
 	hdrbuf, err := json2.Marshal(hdrs)
 	if err != nil {
 		return nil, errors.Wrap(err, `failed to marshal headers`)
@@ -129,14 +152,19 @@ func Sign(payload []byte, alg jwa2.SignatureAlgorithm, key interface{}, options
 	if err := enc.Close(); err != nil {
 		return nil, errors.Wrap(err, `failed to finalize writing headers as base64`)
 	}
+	protectedLen := buf.Len()
 
 	buf.WriteByte('.')
-	enc = base64.NewEncoder(base64.RawURLEncoding, buf)
-	if _, err := enc.Write(payload); err != nil {
-		return nil, errors.Wrap(err, `failed to write payload as base64`)
-	}
-	if err := enc.Close(); err != nil {
-		return nil, errors.Wrap(err, `failed to finalize writing payload as base64`)
+	if encoded {
+		enc = base64.NewEncoder(base64.RawURLEncoding, buf)
+		if _, err := enc.Write(payload); err != nil {
+			return nil, errors.Wrap(err, `failed to write payload as base64`)
+		}
+		if err := enc.Close(); err != nil {
+			return nil, errors.Wrap(err, `failed to finalize writing payload as base64`)
+		}
+	} else {
+		buf.Write(payload)
 	}
 
 	signature, err := signer.Sign(buf.Bytes(), key)
@@ -144,6 +172,10 @@ func Sign(payload []byte, alg jwa2.SignatureAlgorithm, key interface{}, options
 		return nil, errors.Wrap(err, `failed to sign payload`)
 	}
 
+	if detached {
+		buf.Truncate(protectedLen + 1)
+	}
+
 	buf.WriteByte('.')
 	enc = base64.NewEncoder(base64.RawURLEncoding, buf)
 	if _, err := enc.Write(signature); err != nil {
@@ -271,7 +303,11 @@ func SignMulti(payload []byte, options ...Option) ([]byte, error) {
 // `Verifier` in `verify` subpackage, and call `Verify` method on it.
 // If you need to access signatures and JOSE headers in a JWS message,
 // use `Parse` function to get `Message` object.
-func Verify(buf []byte, alg jwa2.SignatureAlgorithm, key interface{}) (ret []byte, err error) {
+//
+// Messages listing header parameters in their "crit" header that are
+// neither registered via RegisterCriticalHeader nor passed via
+// WithCriticalHeaders are rejected.
+func Verify(buf []byte, alg jwa2.SignatureAlgorithm, key interface{}, options ...Option) (ret []byte, err error) {
 	verifier, err := verify2.New(alg)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create verifier")
@@ -311,6 +347,15 @@ func Verify(buf []byte, alg jwa2.SignatureAlgorithm, key interface{}) (ret []byt
 		buf := pool2.GetBytesBuffer()
 		defer pool2.ReleaseBytesBuffer(buf)
 		for _, sig := range proxy.Signatures {
+			hdrs, err := decodeProtectedHeaders([]byte(sig.Protected))
+			if err != nil {
+				continue
+			}
+			encoded, err := checkHeaders(hdrs, options...)
+			if err != nil {
+				continue
+			}
+
 			buf.Reset()
 			buf.WriteString(sig.Protected)
 			buf.WriteByte('.')
@@ -322,6 +367,9 @@ func Verify(buf []byte, alg jwa2.SignatureAlgorithm, key interface{}) (ret []byt
 
 			if err := verifier.Verify(buf.Bytes(), decodedSignature, key); err == nil {
 				// verified!
+				if !encoded {
+					return []byte(proxy.Payload), nil
+				}
 				decodedPayload, err := base64.RawURLEncoding.DecodeString(proxy.Payload)
 				if err != nil {
 					return nil, errors.Wrap(err, `message verified, failed to decode payload`)
@@ -337,6 +385,15 @@ func Verify(buf []byte, alg jwa2.SignatureAlgorithm, key interface{}) (ret []byt
 		return nil, errors.Wrap(err, `failed extract from compact serialization format`)
 	}
 
+	hdrs, err := decodeProtectedHeaders(protected)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := checkHeaders(hdrs, options...)
+	if err != nil {
+		return nil, err
+	}
+
 	verifyBuf := pool2.GetBytesBuffer()
 	defer pool2.ReleaseBytesBuffer(verifyBuf)
 
@@ -352,6 +409,12 @@ func Verify(buf []byte, alg jwa2.SignatureAlgorithm, key interface{}) (ret []byt
 		return nil, errors.Wrap(err, `failed to verify message`)
 	}
 
+	if !encoded {
+		ret = make([]byte, len(payload))
+		copy(ret, payload)
+		return ret, nil
+	}
+
 	decodedPayload := make([]byte, base64.RawURLEncoding.DecodedLen(len(payload)))
 	if _, err := base64.RawURLEncoding.Decode(decodedPayload, payload); err != nil {
 		return nil, errors.Wrap(err, `message verified, failed to decode payload`)
@@ -390,15 +453,15 @@ func VerifyWithJWK(buf []byte, key jwk2.Key) (payload []byte, err error) {
 }
 
 // VerifyWithJWKSet verifies the JWS message using JWK key set.
-// By default it will only pick up keys that have the "use" key
-// set to either "sig" or "enc", but you can override it by
-// providing a keyaccept function.
+// By default it will only pick up keys that are usable for verifying
+// signatures (see DefaultJWKAcceptor), but you can override it by
+// providing a keyaccept function such as PermissiveJWKAcceptor.
 func VerifyWithJWKSet(buf []byte, keyset *jwk2.Set, keyaccept JWKAcceptFunc) ([]byte, error) {
 	if keyaccept == nil {
 		keyaccept = DefaultJWKAcceptor
 	}
 
-	for _, key := range keyset.Keys {
+	for _, key := range keyset.Keys() {
 		if !keyaccept(key) {
 			continue
 		}
@@ -621,9 +684,17 @@ func parseCompact(rdr io.Reader) (m *Message, err error) {
 		return nil, errors.Wrap(err, `failed to parse JOSE headers`)
 	}
 
-	decodedPayload := make([]byte, base64.RawURLEncoding.DecodedLen(len(payload)))
-	if _, err = base64.RawURLEncoding.Decode(decodedPayload, payload); err != nil {
-		return nil, errors.Wrap(err, `failed to decode payload`)
+	encoded, err := payloadEncoded(&hdr)
+	if err != nil {
+		return nil, errors.Wrap(err, `invalid JOSE headers`)
+	}
+
+	decodedPayload := payload
+	if encoded {
+		decodedPayload = make([]byte, base64.RawURLEncoding.DecodedLen(len(payload)))
+		if _, err = base64.RawURLEncoding.Decode(decodedPayload, payload); err != nil {
+			return nil, errors.Wrap(err, `failed to decode payload`)
+		}
 	}
 
 	decodedSignature := make([]byte, base64.RawURLEncoding.DecodedLen(len(signature)))
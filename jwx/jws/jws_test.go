@@ -0,0 +1,182 @@
+package jws_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwa"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jws"
+)
+
+func TestDetached(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	payload := []byte(`{"event":"payment.succeeded","amount":"10.00"}`)
+
+	for _, encoded := range []bool{true, false} {
+		hdrs := jws.NewHeaders()
+		if !encoded {
+			if err := hdrs.Set(jws.B64Key, false); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		signed, err := jws.SignDetached(payload, jwa.HS256, key, jws.WithHeaders(hdrs))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msg, err := jws.ParseString(string(signed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msg.Payload()) != 0 {
+			t.Errorf("b64=%t: expected payload to be detached, got %s", encoded, signed)
+		}
+		if !encoded && (len(hdrs.Critical()) != 1 || hdrs.Critical()[0] != jws.B64Key) {
+			t.Errorf("b64=%t: expected b64 to be critical, got %v", encoded, hdrs.Critical())
+		}
+
+		if err := jws.VerifyDetached(payload, signed, jwa.HS256, key); err != nil {
+			t.Errorf("b64=%t: %s", encoded, err)
+		}
+		if err := jws.VerifyDetached([]byte(`{"event":"payment.failed"}`), signed, jwa.HS256, key); err == nil {
+			t.Errorf("b64=%t: expected tampered payload to fail verification", encoded)
+		}
+	}
+
+	// unencoded, attached payloads are returned as is
+	hdrs := jws.NewHeaders()
+	if err := hdrs.Set(jws.B64Key, false); err != nil {
+		t.Fatal(err)
+	}
+	signed, err := jws.Sign([]byte("$.02"), jwa.HS256, key, jws.WithHeaders(hdrs))
+	if err == nil {
+		t.Error("expected unencoded payload containing '.' to be rejected")
+	}
+	signed, err = jws.Sign([]byte("hello"), jwa.HS256, key, jws.WithHeaders(hdrs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified, err := jws.Verify(signed, jwa.HS256, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(verified) != "hello" {
+		t.Errorf("expected unencoded payload, got %s", verified)
+	}
+}
+
+func TestCritical(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	for _, tc := range []struct {
+		headers string
+		valid   bool
+	}{
+		{`{"alg":"HS256"}`, true},
+		{`{"alg":"HS256","crit":["exp"],"exp":1363284000}`, false},
+		{`{"alg":"HS256","crit":["kid"],"kid":"key"}`, false},
+		{`{"alg":"HS256","crit":["b64"]}`, false},
+		{`{"alg":"HS256","b64":false}`, false},
+		{`{"alg":"HS256","crit":["b64"],"b64":true}`, true},
+	} {
+		signed, err := jws.SignLiteral([]byte("hello"), jwa.HS256, key, []byte(tc.headers))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = jws.Verify(signed, jwa.HS256, key)
+		if tc.valid && err != nil {
+			t.Errorf("%s: %s", tc.headers, err)
+		} else if !tc.valid && err == nil {
+			t.Errorf("%s: expected verification to fail", tc.headers)
+		}
+	}
+}
+
+func TestCriticalHeaders(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	signed, err := jws.SignLiteral([]byte("hello"), jwa.HS256, key, []byte(`{"alg":"HS256","crit":["urn:example:tenant"],"urn:example:tenant":"acme"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := jws.Verify(signed, jwa.HS256, key); err == nil {
+		t.Error("expected unknown critical header to be rejected")
+	}
+	if _, err := jws.Verify(signed, jwa.HS256, key, jws.WithCriticalHeaders("urn:example:tenant")); err != nil {
+		t.Error(err)
+	}
+
+	if err := jws.RegisterCriticalHeader(jws.KeyIDKey); err == nil {
+		t.Error("expected standard header to be rejected")
+	}
+	if err := jws.RegisterCriticalHeader("urn:example:tenant"); err != nil {
+		t.Fatal(err)
+	}
+	defer jws.UnregisterCriticalHeader("urn:example:tenant")
+	if _, err := jws.Verify(signed, jwa.HS256, key); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAsymmetric(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		alg     jwa.SignatureAlgorithm
+		private interface{}
+		public  interface{}
+	}{
+		{jwa.ES256, p256, &p256.PublicKey},
+		{jwa.ES512, p521, &p521.PublicKey},
+		{jwa.EdDSA, edPrivate, edPublic},
+	} {
+		signed, err := jws.Sign([]byte("hello"), tc.alg, tc.private)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.alg, err)
+		}
+		verified, err := jws.Verify(signed, tc.alg, tc.public)
+		if err != nil {
+			t.Errorf("%s: %s", tc.alg, err)
+		} else if string(verified) != "hello" {
+			t.Errorf("%s: unexpected payload %s", tc.alg, verified)
+		}
+	}
+
+	signed, err := jws.Sign([]byte("hello"), jwa.ES256, p256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jws.Verify(signed, jwa.ES256, &other.PublicKey); err == nil {
+		t.Error("expected verification with another key to fail")
+	}
+
+	// keys on another curve than the one of the algorithm are rejected, even
+	// if the signature is valid
+	signed, err = jws.Sign([]byte("hello"), jwa.ES256, p521)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jws.Verify(signed, jwa.ES256, &p521.PublicKey); err == nil {
+		t.Error("expected verification with a P-521 key for ES256 to fail")
+	}
+}
@@ -9,6 +9,7 @@ type Option = option.Interface
 
 type identPayloadSigner struct{}
 type identHeaders struct{}
+type identCriticalHeaders struct{}
 
 func WithSigner(signer sign.Signer, key interface{}, public, protected Headers) Option {
 	return option.New(identPayloadSigner{}, &payloadSigner{
@@ -22,3 +23,10 @@ func WithSigner(signer sign.Signer, key interface{}, public, protected Headers)
 func WithHeaders(h Headers) Option {
 	return option.New(identHeaders{}, h)
 }
+
+// WithCriticalHeaders specifies header parameters that the caller
+// understands, and thus accepts to be listed in the "crit" header of the
+// message being verified, in addition to the registered ones.
+func WithCriticalHeaders(names ...string) Option {
+	return option.New(identCriticalHeaders{}, names)
+}
@@ -0,0 +1,90 @@
+package sign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	jwa2 "github.com/whlanuo/traefik-jwt-middleware/jwx/jwa"
+
+	"github.com/whlanuo/traefik-jwt-middleware/errors"
+)
+
+var ecdsaSignFuncs = map[jwa2.SignatureAlgorithm]ecdsaSignFunc{}
+
+func init() {
+	algs := map[jwa2.SignatureAlgorithm]crypto.Hash{
+		jwa2.ES256: crypto.SHA256,
+		jwa2.ES384: crypto.SHA384,
+		jwa2.ES512: crypto.SHA512,
+	}
+
+	for alg, h := range algs {
+		ecdsaSignFuncs[alg] = makeECDSASignFunc(h)
+	}
+}
+
+// makeECDSASignFunc creates a function that signs the payload, and
+// serializes the signature as the concatenation of r and s, each padded to
+// the size of the curve, as required by RFC 7518 section 3.4
+func makeECDSASignFunc(hash crypto.Hash) ecdsaSignFunc {
+	return func(payload []byte, key *ecdsa.PrivateKey) ([]byte, error) {
+		curveBits := key.Curve.Params().BitSize
+		keyBytes := curveBits / 8
+		// Curve bits do not need to be a multiple of 8.
+		if curveBits%8 > 0 {
+			keyBytes++
+		}
+
+		h := hash.New()
+		if _, err := h.Write(payload); err != nil {
+			return nil, errors.Wrap(err, "failed to write payload using ecdsa")
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, key, h.Sum(nil))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to sign payload using ecdsa")
+		}
+
+		rBytes := r.Bytes()
+		sBytes := s.Bytes()
+		out := make([]byte, 2*keyBytes)
+		copy(out[keyBytes-len(rBytes):keyBytes], rBytes)
+		copy(out[2*keyBytes-len(sBytes):], sBytes)
+		return out, nil
+	}
+}
+
+func newECDSA(alg jwa2.SignatureAlgorithm) (*ECDSASigner, error) {
+	signfn, ok := ecdsaSignFuncs[alg]
+	if !ok {
+		return nil, errors.Errorf(`unsupported algorithm while trying to create ECDSA signer: %s`, alg)
+	}
+
+	return &ECDSASigner{
+		alg:  alg,
+		sign: signfn,
+	}, nil
+}
+
+func (s ECDSASigner) Algorithm() jwa2.SignatureAlgorithm {
+	return s.alg
+}
+
+// Sign creates a signature using crypto/ecdsa. key must be a non-nil instance of
+// `*"crypto/ecdsa".PrivateKey`.
+func (s ECDSASigner) Sign(payload []byte, key interface{}) ([]byte, error) {
+	if key == nil {
+		return nil, errors.New(`missing private key while signing payload`)
+	}
+
+	var privkey *ecdsa.PrivateKey
+	switch v := key.(type) {
+	case ecdsa.PrivateKey:
+		privkey = &v
+	case *ecdsa.PrivateKey:
+		privkey = v
+	default:
+		return nil, errors.Errorf(`invalid key type %T. *ecdsa.PrivateKey is required`, key)
+	}
+
+	return s.sign(payload, privkey)
+}
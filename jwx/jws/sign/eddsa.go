@@ -0,0 +1,39 @@
+package sign
+
+import (
+	"crypto/ed25519"
+	jwa2 "github.com/whlanuo/traefik-jwt-middleware/jwx/jwa"
+
+	"github.com/whlanuo/traefik-jwt-middleware/errors"
+)
+
+func newEdDSA() (*EdDSASigner, error) {
+	return &EdDSASigner{}, nil
+}
+
+func (s EdDSASigner) Algorithm() jwa2.SignatureAlgorithm {
+	return jwa2.EdDSA
+}
+
+// Sign creates a signature using crypto/ed25519. key must be an instance of
+// `"crypto/ed25519".PrivateKey`.
+func (s EdDSASigner) Sign(payload []byte, key interface{}) ([]byte, error) {
+	if key == nil {
+		return nil, errors.New(`missing private key while signing payload`)
+	}
+
+	var privkey ed25519.PrivateKey
+	switch v := key.(type) {
+	case ed25519.PrivateKey:
+		privkey = v
+	case *ed25519.PrivateKey:
+		privkey = *v
+	default:
+		return nil, errors.Errorf(`invalid key type %T. ed25519.PrivateKey is required`, key)
+	}
+	if len(privkey) != ed25519.PrivateKeySize {
+		return nil, errors.Errorf(`invalid ed25519 private key size %d`, len(privkey))
+	}
+
+	return ed25519.Sign(privkey, payload), nil
+}
@@ -12,6 +12,10 @@ func New(alg jwa.SignatureAlgorithm) (Signer, error) {
 		return newRSA(alg)
 	case jwa.HS256, jwa.HS384, jwa.HS512:
 		return newHMAC(alg)
+	case jwa.ES256, jwa.ES384, jwa.ES512:
+		return newECDSA(alg)
+	case jwa.EdDSA:
+		return newEdDSA()
 	default:
 		return nil, errors.Errorf(`unsupported signature algorithm %s`, alg)
 	}
@@ -0,0 +1,87 @@
+package verify
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	jwa2 "github.com/whlanuo/traefik-jwt-middleware/jwx/jwa"
+	"math/big"
+
+	"github.com/whlanuo/traefik-jwt-middleware/errors"
+)
+
+var ecdsaVerifyFuncs = map[jwa2.SignatureAlgorithm]ecdsaVerifyFunc{}
+
+func init() {
+	algs := map[jwa2.SignatureAlgorithm]struct {
+		hash  crypto.Hash
+		curve elliptic.Curve
+	}{
+		jwa2.ES256: {crypto.SHA256, elliptic.P256()},
+		jwa2.ES384: {crypto.SHA384, elliptic.P384()},
+		jwa2.ES512: {crypto.SHA512, elliptic.P521()},
+	}
+
+	for alg, params := range algs {
+		ecdsaVerifyFuncs[alg] = makeECDSAVerifyFunc(params.hash, params.curve)
+	}
+}
+
+// makeECDSAVerifyFunc creates a function that verifies a signature
+// serialized as the concatenation of r and s, as required by RFC 7518
+// section 3.4. Keys on other curves than the one of the algorithm are
+// rejected.
+func makeECDSAVerifyFunc(hash crypto.Hash, curve elliptic.Curve) ecdsaVerifyFunc {
+	return func(payload []byte, signature []byte, key *ecdsa.PublicKey) error {
+		if key.Curve != curve {
+			return errors.Errorf(`invalid curve %s for algorithm, %s is required`, key.Curve.Params().Name, curve.Params().Name)
+		}
+
+		keySize := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*keySize {
+			return errors.Errorf(`invalid signature length %d for curve %s`, len(signature), key.Curve.Params().Name)
+		}
+
+		r := new(big.Int).SetBytes(signature[:keySize])
+		s := new(big.Int).SetBytes(signature[keySize:])
+
+		h := hash.New()
+		if _, err := h.Write(payload); err != nil {
+			return errors.Wrap(err, "failed to write payload using ecdsa")
+		}
+
+		if !ecdsa.Verify(key, h.Sum(nil), r, s) {
+			return errors.New(`failed to verify signature using ecdsa`)
+		}
+		return nil
+	}
+}
+
+func newECDSA(alg jwa2.SignatureAlgorithm) (*ECDSAVerifier, error) {
+	verifyfn, ok := ecdsaVerifyFuncs[alg]
+	if !ok {
+		return nil, errors.Errorf(`unsupported algorithm while trying to create ECDSA verifier: %s`, alg)
+	}
+
+	return &ECDSAVerifier{
+		verify: verifyfn,
+	}, nil
+}
+
+func (v ECDSAVerifier) Verify(payload, signature []byte, key interface{}) error {
+	if key == nil {
+		return errors.New(`missing public key while verifying payload`)
+	}
+
+	var pubkey *ecdsa.PublicKey
+	switch v := key.(type) {
+	case ecdsa.PublicKey:
+		pubkey = &v
+	case *ecdsa.PublicKey:
+		pubkey = v
+	default:
+		return errors.Errorf(`invalid key type %T. *ecdsa.PublicKey is required`, key)
+	}
+
+	return v.verify(payload, signature, pubkey)
+}
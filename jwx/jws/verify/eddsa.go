@@ -0,0 +1,35 @@
+package verify
+
+import (
+	"crypto/ed25519"
+
+	"github.com/whlanuo/traefik-jwt-middleware/errors"
+)
+
+func newEdDSA() (*EdDSAVerifier, error) {
+	return &EdDSAVerifier{}, nil
+}
+
+func (v EdDSAVerifier) Verify(payload, signature []byte, key interface{}) error {
+	if key == nil {
+		return errors.New(`missing public key while verifying payload`)
+	}
+
+	var pubkey ed25519.PublicKey
+	switch v := key.(type) {
+	case ed25519.PublicKey:
+		pubkey = v
+	case *ed25519.PublicKey:
+		pubkey = *v
+	default:
+		return errors.Errorf(`invalid key type %T. ed25519.PublicKey is required`, key)
+	}
+	if len(pubkey) != ed25519.PublicKeySize {
+		return errors.Errorf(`invalid ed25519 public key size %d`, len(pubkey))
+	}
+
+	if !ed25519.Verify(pubkey, payload, signature) {
+		return errors.New(`failed to verify signature using ed25519`)
+	}
+	return nil
+}
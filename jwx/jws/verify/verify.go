@@ -13,6 +13,10 @@ func New(alg jwa.SignatureAlgorithm) (Verifier, error) {
 		return newRSA(alg)
 	case jwa.HS256, jwa.HS384, jwa.HS512:
 		return newHMAC(alg)
+	case jwa.ES256, jwa.ES384, jwa.ES512:
+		return newECDSA(alg)
+	case jwa.EdDSA:
+		return newEdDSA()
 	default:
 		return nil, errors.Errorf(`unsupported signature algorithm: %#v`, alg)
 	}
@@ -0,0 +1,36 @@
+package jwt
+
+import "fmt"
+
+// UnknownKeyIDError is returned by Parse when the key set given via
+// WithKeySet contains no key matching the Key ID of the JWT. This
+// usually means that the key set is outdated, for example because the
+// issuer has rotated its keys, and should be fetched again.
+type UnknownKeyIDError struct {
+	keyID string
+}
+
+// KeyID returns the Key ID that could not be found in the key set
+func (e *UnknownKeyIDError) KeyID() string {
+	return e.keyID
+}
+
+func (e *UnknownKeyIDError) Error() string {
+	return fmt.Sprintf(`failed to find matching key for key ID %#v in key set`, e.keyID)
+}
+
+// TypeHeaderError is returned by Parse when the "typ" header of the JWT
+// does not match the media types given via WithTypeHeader
+type TypeHeaderError struct {
+	typ      string
+	expected []string
+}
+
+// Type returns the "typ" header of the JWT, which is empty if missing
+func (e *TypeHeaderError) Type() string {
+	return e.typ
+}
+
+func (e *TypeHeaderError) Error() string {
+	return fmt.Sprintf(`typ header %#v does not match any of %#v`, e.typ, e.expected)
+}
@@ -41,14 +41,26 @@ func ParseBytes(s []byte, options ...Option) (Token, error) {
 // This function takes both ParseOption and Validate Option types:
 // ParseOptions control the parsing behavior, and ValidateOptions are
 // passed to `Validate()` when `jwt.WithValidate` is specified.
+//
+// When verifying, tokens whose "crit" header lists unknown header
+// parameters are rejected. Pass `jws.WithCriticalHeaders` to accept
+// header parameters that the caller understands.
 func Parse(src io.Reader, options ...Option) (Token, error) {
 	var params VerifyParameters
 	var keyset *jwk2.Set
 	var useDefault bool
+	var tryAll bool
+	var acceptor jws2.JWKAcceptor = jws2.DefaultJWKAcceptor
 	var token Token
 	var validate bool
+	var types []string
+	var keyUsed *jwk2.Key
 	for _, o := range options {
 		switch o.Ident() {
+		case identTypeHeader{}:
+			types = append(types, o.Value().(string))
+		case identAccessTokenProfile{}:
+			types = append(types, accessTokenType)
 		case identVerify{}:
 			params = o.Value().(VerifyParameters)
 		case identKeySet{}:
@@ -57,8 +69,14 @@ func Parse(src io.Reader, options ...Option) (Token, error) {
 			token = o.Value().(Token)
 		case identDefault{}:
 			useDefault = o.Value().(bool)
+		case identTryAllKeys{}:
+			tryAll = o.Value().(bool)
+		case identKeyAcceptor{}:
+			acceptor = o.Value().(jws2.JWKAcceptor)
 		case identValidate{}:
 			validate = o.Value().(bool)
+		case identKeyUsed{}:
+			keyUsed = o.Value().(*jwk2.Key)
 		}
 	}
 
@@ -68,14 +86,31 @@ func Parse(src io.Reader, options ...Option) (Token, error) {
 		return nil, errors.Wrap(err, `failed to read from token data source`)
 	}
 
+	if len(types) > 0 {
+		if err := checkTypeHeader(data, types); err != nil {
+			return nil, err
+		}
+	}
+
 	// If with matching kid is true, then look for the corresponding key in the
 	// given key set, by matching the "kid" key
+	if keyset != nil && tryAll {
+		return parseWithCandidateKeys(token, data, keyset, acceptor, validate, keyUsed, options...)
+	}
+
 	if keyset != nil {
-		alg, key, err := lookupMatchingKey(data, keyset, useDefault)
+		alg, key, rawKey, err := lookupMatchingKey(data, keyset, acceptor, useDefault)
 		if err != nil {
 			return nil, errors.Wrap(err, `failed to find matching key for verification`)
 		}
-		return parse(token, data, true, alg, key, validate, options...)
+		token, err = parse(token, data, true, alg, rawKey, validate, options...)
+		if err != nil {
+			return nil, err
+		}
+		if keyUsed != nil {
+			*keyUsed = key
+		}
+		return token, nil
 	}
 
 	if params != nil {
@@ -90,7 +125,7 @@ func Parse(src io.Reader, options ...Option) (Token, error) {
 func parse(token Token, data []byte, verify bool, alg jwa2.SignatureAlgorithm, key interface{}, validate bool, options ...Option) (Token, error) {
 	var payload []byte
 	if verify {
-		v, err := jws2.Verify(data, alg, key)
+		v, err := jws2.Verify(data, alg, key, options...)
 		if err != nil {
 			return nil, errors.Wrap(err, `failed to verify jws signature`)
 		}
@@ -104,6 +139,9 @@ func parse(token Token, data []byte, verify bool, alg jwa2.SignatureAlgorithm, k
 			return nil, errors.Wrap(err, `invalid jws message`)
 		}
 		payload = m.Payload()
+		if sigs := m.Signatures(); len(sigs) > 0 {
+			alg = sigs[0].ProtectedHeaders().Algorithm()
+		}
 
 		// If JWS parse did not produce a full JWS message but also
 		// there were no errors, assume that this is an unsigned, raw
@@ -113,6 +151,12 @@ func parse(token Token, data []byte, verify bool, alg jwa2.SignatureAlgorithm, k
 		}
 	}
 
+	return parsePayload(token, payload, alg, validate, options...)
+}
+
+// parsePayload creates the token from the already verified payload, which
+// was signed using the given algorithm
+func parsePayload(token Token, payload []byte, alg jwa2.SignatureAlgorithm, validate bool, options ...Option) (Token, error) {
 	if token == nil {
 		token = New()
 	}
@@ -132,41 +176,286 @@ func parse(token Token, data []byte, verify bool, alg jwa2.SignatureAlgorithm, k
 			return nil, err
 		}
 	}
+
+	if err := validateProfiles(token, alg, options...); err != nil {
+		return nil, err
+	}
 	return token, nil
 }
 
-func lookupMatchingKey(data []byte, keyset *jwk2.Set, useDefault bool) (jwa2.SignatureAlgorithm, interface{}, error) {
+// ValidateProfiles validates the claims of the token against the profiles
+// given with `WithAccessTokenProfile()` or `WithIDTokenProfile()`, like
+// `Parse()` does. It is meant for claims that were not parsed from a JWS,
+// such as the claims of an introspected token: the "typ" header required by
+// the access token profile is not checked, and "at_hash" or "c_hash" claims
+// cannot be verified without the signature algorithm. Other options are
+// ignored.
+func ValidateProfiles(t Token, options ...Option) error {
+	return validateProfiles(t, "", options...)
+}
+
+func validateProfiles(t Token, alg jwa2.SignatureAlgorithm, options ...Option) error {
+	for _, o := range options {
+		switch o.Ident() {
+		case identAccessTokenProfile{}:
+			if err := o.Value().(*accessTokenProfile).validate(t); err != nil {
+				return err
+			}
+		case identIDTokenProfile{}:
+			if err := o.Value().(*idTokenProfile).validate(t, alg, options...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkTypeHeader makes sure that the "typ" header of the token is one of
+// the given media types
+func checkTypeHeader(data []byte, types []string) error {
+	msg, err := jws2.Parse(bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, `failed to parse token data`)
+	}
+
+	var typ string
+	if len(msg.Signatures()) > 0 {
+		typ = msg.Signatures()[0].ProtectedHeaders().Type()
+	}
+	for _, expected := range types {
+		if normalizeMediaType(typ) == normalizeMediaType(expected) {
+			return nil
+		}
+	}
+	return &TypeHeaderError{typ: typ, expected: types}
+}
+
+// normalizeMediaType lower cases the media type and strips the optional
+// "application/" prefix, as described in RFC 7515 section 4.1.9
+func normalizeMediaType(typ string) string {
+	typ = strings.ToLower(typ)
+	if rest := strings.TrimPrefix(typ, "application/"); !strings.Contains(rest, "/") {
+		return rest
+	}
+	return typ
+}
+
+func lookupMatchingKey(data []byte, keyset *jwk2.Set, acceptor jws2.JWKAcceptor, useDefault bool) (jwa2.SignatureAlgorithm, jwk2.Key, interface{}, error) {
+	headers, err := protectedHeaders(data)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	key, err := matchingKey(headers, keyset, acceptor, useDefault)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	var rawKey interface{}
+	if err := key.Raw(&rawKey); err != nil {
+		return "", nil, nil, errors.Wrapf(err, `failed to construct raw key from keyset (key ID=%#v)`, headers.KeyID())
+	}
+
+	return headers.Algorithm(), key, rawKey, nil
+}
+
+// protectedHeaders returns the protected headers of the first signature
+// of the JWS message
+func protectedHeaders(data []byte) (jws2.Headers, error) {
 	msg, err := jws2.Parse(bytes.NewReader(data))
 	if err != nil {
-		return "", nil, errors.Wrap(err, `failed to parse token data`)
+		return nil, errors.Wrap(err, `failed to parse token data`)
 	}
+	return msg.Signatures()[0].ProtectedHeaders(), nil
+}
 
-	headers := msg.Signatures()[0].ProtectedHeaders()
+// matchingKey returns the single key of the key set matching the key ID
+// of the headers, or the only key if there is no key ID and useDefault is
+// true
+func matchingKey(headers jws2.Headers, keyset *jwk2.Set, acceptor jws2.JWKAcceptor, useDefault bool) (jwk2.Key, error) {
 	kid := headers.KeyID()
+	if kid == "" && !useDefault {
+		return nil, errors.New(`failed to find matching key: no key ID specified in token`)
+	}
+
+	var keys []jwk2.Key
 	if kid == "" {
-		if !useDefault {
-			return "", nil, errors.New(`failed to find matching key: no key ID specified in token`)
-		} else if useDefault && keyset.Len() > 1 {
-			return "", nil, errors.New(`failed to find matching key: no key ID specified in token but multiple in key set`)
+		keys = keyset.Keys()
+	} else {
+		keys = keyset.LookupKeyID(kid)
+	}
+	if len(keys) == 0 {
+		if kid != "" {
+			return nil, &UnknownKeyIDError{keyID: kid}
 		}
+		return nil, errors.New(`failed to find matching key: key set is empty`)
+	}
+
+	keys = acceptKeys(keys, acceptor)
+	if len(keys) == 0 {
+		return nil, errors.Errorf(`failed to find matching key for key ID %#v: no key is usable for verification`, kid)
 	}
 
+	if kid == "" && len(keys) > 1 {
+		return nil, errors.New(`failed to find matching key: no key ID specified in token but multiple in key set`)
+	}
+	return keys[0], nil
+}
+
+// LookupKeys returns the signature algorithm of the headers, and the keys
+// of the key set that Parse would verify a JWS with these protected headers
+// with, honoring the UseDefaultKey, TryAllKeys and WithKeyAcceptor options.
+// It is meant for JWS messages that are not parsed as a JWT, such as
+// detached signatures. Like Parse, it returns an *UnknownKeyIDError if the
+// key set has no key matching the key ID. Other options are ignored.
+func LookupKeys(headers jws2.Headers, keyset *jwk2.Set, options ...Option) (jwa2.SignatureAlgorithm, []jwk2.Key, error) {
+	var useDefault bool
+	var tryAll bool
+	var acceptor jws2.JWKAcceptor = jws2.DefaultJWKAcceptor
+	for _, o := range options {
+		switch o.Ident() {
+		case identDefault{}:
+			useDefault = o.Value().(bool)
+		case identTryAllKeys{}:
+			tryAll = o.Value().(bool)
+		case identKeyAcceptor{}:
+			acceptor = o.Value().(jws2.JWKAcceptor)
+		}
+	}
+
+	if tryAll {
+		keys, err := candidateKeys(headers, keyset, acceptor)
+		if err != nil {
+			return "", nil, err
+		}
+		return headers.Algorithm(), keys, nil
+	}
+
+	key, err := matchingKey(headers, keyset, acceptor, useDefault)
+	if err != nil {
+		return "", nil, err
+	}
+	return headers.Algorithm(), []jwk2.Key{key}, nil
+}
+
+// parseWithCandidateKeys tries each candidate key in the key set until
+// one of them verifies the signature. If keyUsed is not nil, it is set to
+// the key that verified the signature.
+func parseWithCandidateKeys(token Token, data []byte, keyset *jwk2.Set, acceptor jws2.JWKAcceptor, validate bool, keyUsed *jwk2.Key, options ...Option) (Token, error) {
+	alg, keys, err := lookupCandidateKeys(data, keyset, acceptor)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to find candidate keys for verification`)
+	}
+
+	for _, key := range keys {
+		var rawKey interface{}
+		if err := key.Raw(&rawKey); err != nil {
+			continue
+		}
+
+		payload, err := jws2.Verify(data, alg, rawKey, options...)
+		if err != nil {
+			continue
+		}
+		token, err = parsePayload(token, payload, alg, validate, options...)
+		if err != nil {
+			return nil, err
+		}
+		if keyUsed != nil {
+			*keyUsed = key
+		}
+		return token, nil
+	}
+
+	// As with jws.VerifyWithJWKSet, do not report the last error seen
+	// in the loop: the symptom is that none of the keys worked.
+	return nil, errors.Errorf(`failed to verify jws signature with any of the %d candidate keys`, len(keys))
+}
+
+func lookupCandidateKeys(data []byte, keyset *jwk2.Set, acceptor jws2.JWKAcceptor) (jwa2.SignatureAlgorithm, []jwk2.Key, error) {
+	headers, err := protectedHeaders(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	keys, err := candidateKeys(headers, keyset, acceptor)
+	if err != nil {
+		return "", nil, err
+	}
+	return headers.Algorithm(), keys, nil
+}
+
+// candidateKeys returns the keys of the key set matching the key ID of the
+// headers, or all keys if there is none, that can verify signatures created
+// with the algorithm of the headers
+func candidateKeys(headers jws2.Headers, keyset *jwk2.Set, acceptor jws2.JWKAcceptor) ([]jwk2.Key, error) {
+	alg := headers.Algorithm()
+	kid := headers.KeyID()
+
 	var keys []jwk2.Key
 	if kid == "" {
-		keys = keyset.Keys
+		keys = keyset.Keys()
 	} else {
 		keys = keyset.LookupKeyID(kid)
+		if len(keys) == 0 {
+			return nil, &UnknownKeyIDError{keyID: kid}
+		}
 	}
-	if len(keys) == 0 {
-		return "", nil, errors.Errorf(`failed to find matching key for key ID %#v in key set`, kid)
+
+	var candidates []jwk2.Key
+	for _, key := range acceptKeys(keys, acceptor) {
+		if isCompatibleKey(key, alg) {
+			candidates = append(candidates, key)
+		}
 	}
+	if len(candidates) == 0 {
+		return nil, errors.Errorf(`failed to find key compatible with algorithm %s for key ID %#v in key set`, alg, kid)
+	}
+	return candidates, nil
+}
 
-	var rawKey interface{}
-	if err := keys[0].Raw(&rawKey); err != nil {
-		return "", nil, errors.Wrapf(err, `failed to construct raw key from keyset (key ID=%#v)`, kid)
+// isCompatibleKey returns true if the key can be used to verify
+// signatures created with the given algorithm
+func isCompatibleKey(key jwk2.Key, alg jwa2.SignatureAlgorithm) bool {
+	if v := key.Algorithm(); v != "" && v != alg.String() {
+		return false
+	}
+
+	var crv jwa2.EllipticCurveAlgorithm
+	if v, ok := key.(interface {
+		Crv() jwa2.EllipticCurveAlgorithm
+	}); ok {
+		crv = v.Crv()
 	}
 
-	return headers.Algorithm(), rawKey, nil
+	switch alg {
+	case jwa2.HS256, jwa2.HS384, jwa2.HS512:
+		return key.KeyType() == jwa2.OctetSeq
+	case jwa2.RS256, jwa2.RS384, jwa2.RS512, jwa2.PS256, jwa2.PS384, jwa2.PS512:
+		return key.KeyType() == jwa2.RSA
+	case jwa2.ES256:
+		return key.KeyType() == jwa2.EC && crv == jwa2.P256
+	case jwa2.ES384:
+		return key.KeyType() == jwa2.EC && crv == jwa2.P384
+	case jwa2.ES512:
+		return key.KeyType() == jwa2.EC && crv == jwa2.P521
+	case jwa2.EdDSA:
+		return key.KeyType() == jwa2.OKP
+	default:
+		return false
+	}
+}
+
+// acceptKeys returns the keys that the acceptor allows to be used
+// for verification
+func acceptKeys(keys []jwk2.Key, acceptor jws2.JWKAcceptor) []jwk2.Key {
+	var accepted []jwk2.Key
+	for _, key := range keys {
+		if acceptor.Accept(key) {
+			accepted = append(accepted, key)
+		}
+	}
+	return accepted
 }
 
 // ParseVerify is marked to be deprecated. Please use jwt.Parse
@@ -215,8 +504,10 @@ func Sign(t Token, alg jwa2.SignatureAlgorithm, key interface{}, options ...Opti
 		hdr = jws2.NewHeaders()
 	}
 
-	if err := hdr.Set(`typ`, `JWT`); err != nil {
-		return nil, errors.Wrap(err, `failed to sign payload`)
+	if hdr.Type() == "" {
+		if err := hdr.Set(jws2.TypeKey, `JWT`); err != nil {
+			return nil, errors.Wrap(err, `failed to sign payload`)
+		}
 	}
 	sign, err := jws2.Sign(buf, alg, key, jws2.WithHeaders(hdr))
 	if err != nil {
@@ -0,0 +1,395 @@
+package jwt_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwa"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwk"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jws"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwt"
+)
+
+func TestKeyUsage(t *testing.T) {
+	testcases := []struct {
+		Name       string
+		Use        jwk.KeyUsageType
+		KeyOps     jwk.KeyOperationList
+		Permissive bool
+		Error      bool
+	}{
+		{Name: "no use or key_ops"},
+		{Name: "use sig", Use: jwk.ForSignature},
+		{Name: "use enc", Use: jwk.ForEncryption, Error: true},
+		{Name: "key_ops verify", KeyOps: jwk.KeyOperationList{jwk.KeyOpSign, jwk.KeyOpVerify}},
+		{Name: "key_ops without verify", KeyOps: jwk.KeyOperationList{jwk.KeyOpEncrypt, jwk.KeyOpDecrypt}, Error: true},
+		{Name: "use enc with opt-out", Use: jwk.ForEncryption, Permissive: true},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			key, err := jwk.New([]byte("0123456789abcdef0123456789abcdef"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := key.Set(jwk.KeyIDKey, "mykey"); err != nil {
+				t.Fatal(err)
+			}
+			if err := key.Set(jwk.AlgorithmKey, jwa.HS256); err != nil {
+				t.Fatal(err)
+			}
+			if tc.Use != "" {
+				if err := key.Set(jwk.KeyUsageKey, tc.Use); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.KeyOps != nil {
+				if err := key.Set(jwk.KeyOpsKey, tc.KeyOps); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tok := jwt.New()
+			if err := tok.Set(jwt.SubjectKey, "test"); err != nil {
+				t.Fatal(err)
+			}
+			signed, err := jwt.Sign(tok, jwa.HS256, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			set := jwk.NewSet(key)
+			options := []jwt.Option{jwt.WithKeySet(set)}
+			var acceptor jws.JWKAcceptFunc
+			if tc.Permissive {
+				options = append(options, jwt.WithKeyAcceptor(jws.PermissiveJWKAcceptor))
+				acceptor = jws.PermissiveJWKAcceptor
+			}
+
+			_, err = jwt.ParseBytes(signed, options...)
+			if tc.Error && err == nil {
+				t.Error("expected jwt.Parse to fail")
+			} else if !tc.Error && err != nil {
+				t.Errorf("expected jwt.Parse to succeed: %s", err)
+			}
+
+			_, err = jws.VerifyWithJWKSet(signed, set, acceptor)
+			if tc.Error && err == nil {
+				t.Error("expected jws.VerifyWithJWKSet to fail")
+			} else if !tc.Error && err != nil {
+				t.Errorf("expected jws.VerifyWithJWKSet to succeed: %s", err)
+			}
+		})
+	}
+}
+
+func TestTryAllKeys(t *testing.T) {
+	var keys []jwk.Key
+	for _, secret := range []string{"first-secret-0123456789abcdefghij", "second-secret-0123456789abcdefghi"} {
+		key, err := jwk.New([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+
+	rsaKey, err := jwk.Generate(jwa.RSA, jwk.WithKeySize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tok := jwt.New()
+	if err := tok.Set(jwt.SubjectKey, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	var raw []byte
+	if err := keys[1].Raw(&raw); err != nil {
+		t.Fatal(err)
+	}
+	signed, err := jwt.Sign(tok, jwa.HS256, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := jwk.NewSet(rsaKey, keys[0], keys[1])
+	if _, err := jwt.ParseBytes(signed, jwt.WithKeySet(set), jwt.UseDefaultKey(true)); err == nil {
+		t.Error("expected jwt.Parse to fail without a key ID")
+	}
+
+	var used jwk.Key
+	parsed, err := jwt.ParseBytes(signed, jwt.WithKeySet(set), jwt.TryAllKeys(true), jwt.WithKeyUsed(&used))
+	if err != nil {
+		t.Fatalf("expected jwt.Parse to succeed with TryAllKeys: %s", err)
+	}
+	if parsed.Subject() != "test" {
+		t.Errorf("expected subject to be test, got %s", parsed.Subject())
+	}
+	if used != keys[1] {
+		t.Error("expected the key used to be the one that verified the signature")
+	}
+
+	used = nil
+	if _, err := jwt.ParseBytes(signed, jwt.WithKeySet(jwk.NewSet(keys[1])), jwt.UseDefaultKey(true), jwt.WithKeyUsed(&used)); err != nil {
+		t.Fatalf("expected jwt.Parse to succeed with the default key: %s", err)
+	}
+	if used != keys[1] {
+		t.Error("expected the key used to be the default key")
+	}
+
+	if _, err := jwt.ParseBytes(signed, jwt.WithKeySet(jwk.NewSet(rsaKey, keys[0])), jwt.TryAllKeys(true)); err == nil {
+		t.Error("expected jwt.Parse to fail when no candidate key verifies")
+	}
+}
+
+func TestCriticalHeaders(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	hdrs := jws.NewHeaders()
+	if err := hdrs.Set(jws.CriticalKey, []string{"urn:example:tenant"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hdrs.Set("urn:example:tenant", "acme"); err != nil {
+		t.Fatal(err)
+	}
+	signed, err := jwt.Sign(jwt.New(), jwa.HS256, key, jwt.WithHeaders(hdrs))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := jwt.ParseBytes(signed, jwt.WithVerify(jwa.HS256, key)); err == nil {
+		t.Error("expected unknown critical header to be rejected")
+	}
+	if _, err := jwt.ParseBytes(signed, jwt.WithVerify(jwa.HS256, key), jws.WithCriticalHeaders("urn:example:tenant")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTypeHeader(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	sign := func(typ string) []byte {
+		hdrs := jws.NewHeaders()
+		if len(typ) > 0 {
+			if err := hdrs.Set(jws.TypeKey, typ); err != nil {
+				t.Fatal(err)
+			}
+		}
+		signed, err := jwt.Sign(jwt.New(), jwa.HS256, key, jwt.WithHeaders(hdrs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	for _, tc := range []struct {
+		typ      string
+		expected string
+		valid    bool
+	}{
+		{"at+jwt", "at+jwt", true},
+		{"application/at+jwt", "at+jwt", true},
+		{"AT+JWT", "application/at+jwt", true},
+		{"", "at+jwt", false},
+		{"logout+jwt", "at+jwt", false},
+	} {
+		_, err := jwt.ParseBytes(sign(tc.typ), jwt.WithVerify(jwa.HS256, key), jwt.WithTypeHeader(tc.expected))
+		if tc.valid && err != nil {
+			t.Errorf("typ %#v, expected %#v: %s", tc.typ, tc.expected, err)
+		}
+		if !tc.valid {
+			var typeErr *jwt.TypeHeaderError
+			if !errors.As(err, &typeErr) {
+				t.Errorf("typ %#v, expected %#v: expected *jwt.TypeHeaderError, got %v", tc.typ, tc.expected, err)
+			}
+		}
+	}
+}
+
+func TestAccessTokenProfile(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	claims := func() map[string]interface{} {
+		return map[string]interface{}{
+			jwt.IssuerKey:     "https://issuer.example.com",
+			jwt.ExpirationKey: time.Now().Add(time.Hour),
+			jwt.AudienceKey:   []string{"https://api.example.com"},
+			jwt.SubjectKey:    "alice",
+			jwt.ClientIDKey:   "client",
+			jwt.IssuedAtKey:   time.Now(),
+			jwt.JwtIDKey:      "id",
+			jwt.ScopeKey:      "openid profile",
+			jwt.RolesKey:      []string{"admin"},
+		}
+	}
+
+	testcases := []struct {
+		Name    string
+		Typ     string
+		Modify  func(map[string]interface{})
+		Claim   string
+		Missing bool
+		Error   bool
+	}{
+		{Name: "valid", Typ: "at+jwt"},
+		{Name: "valid with media type", Typ: "application/at+jwt"},
+		{Name: "wrong typ", Typ: "JWT", Error: true},
+		{Name: "missing client_id", Typ: "at+jwt", Modify: func(c map[string]interface{}) { delete(c, jwt.ClientIDKey) }, Claim: jwt.ClientIDKey, Missing: true},
+		{Name: "missing jti", Typ: "at+jwt", Modify: func(c map[string]interface{}) { delete(c, jwt.JwtIDKey) }, Claim: jwt.JwtIDKey, Missing: true},
+		{Name: "missing issuer", Typ: "at+jwt", Modify: func(c map[string]interface{}) { delete(c, jwt.IssuerKey) }, Claim: jwt.IssuerKey, Missing: true},
+		{Name: "wrong issuer", Typ: "at+jwt", Modify: func(c map[string]interface{}) { c[jwt.IssuerKey] = "https://evil.example.com" }, Claim: jwt.IssuerKey},
+		{Name: "wrong audience", Typ: "at+jwt", Modify: func(c map[string]interface{}) { c[jwt.AudienceKey] = []string{"other"} }, Claim: jwt.AudienceKey},
+		{Name: "invalid scope", Typ: "at+jwt", Modify: func(c map[string]interface{}) { c[jwt.ScopeKey] = "openid  \"profile\"" }, Claim: jwt.ScopeKey},
+		{Name: "invalid groups", Typ: "at+jwt", Modify: func(c map[string]interface{}) { c[jwt.GroupsKey] = "admins" }, Claim: jwt.GroupsKey},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			c := claims()
+			if tc.Modify != nil {
+				tc.Modify(c)
+			}
+			tok := jwt.New()
+			for name, value := range c {
+				if err := tok.Set(name, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			hdrs := jws.NewHeaders()
+			if err := hdrs.Set(jws.TypeKey, tc.Typ); err != nil {
+				t.Fatal(err)
+			}
+			signed, err := jwt.Sign(tok, jwa.HS256, key, jwt.WithHeaders(hdrs))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = jwt.ParseBytes(signed, jwt.WithVerify(jwa.HS256, key), jwt.WithAccessTokenProfile("https://issuer.example.com", "https://api.example.com"))
+			if len(tc.Claim) > 0 {
+				var claimErr *jwt.InvalidClaimError
+				if !errors.As(err, &claimErr) || claimErr.Claim() != tc.Claim || claimErr.Missing() != tc.Missing {
+					t.Errorf("expected invalid %s claim (missing: %v), got %v", tc.Claim, tc.Missing, err)
+				}
+			} else if tc.Error && err == nil {
+				t.Error("expected error")
+			} else if !tc.Error && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestIDTokenProfile(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef0123456789abcdef")
+	const accessToken = "jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y"
+	const code = "Qcb0Orv1zh30vL1MPRsbm-diHiMwcLyZvn1arpZv-Jxf_11jnpEX3Tgfvk"
+
+	claims := func(alg jwa.SignatureAlgorithm) map[string]interface{} {
+		atHash, err := jwt.TokenHash(accessToken, alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cHash, err := jwt.TokenHash(code, alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return map[string]interface{}{
+			jwt.IssuerKey:          "https://issuer.example.com",
+			jwt.SubjectKey:         "alice",
+			jwt.AudienceKey:        []string{"client"},
+			jwt.ExpirationKey:      time.Now().Add(time.Hour),
+			jwt.IssuedAtKey:        time.Now(),
+			jwt.AuthTimeKey:        time.Now().Add(-time.Minute).Unix(),
+			jwt.NonceKey:           "n-0S6_WzA2Mj",
+			jwt.EmailKey:           "alice@example.com",
+			jwt.EmailVerifiedKey:   true,
+			jwt.AddressKey:         map[string]interface{}{"locality": "Paris", "country": "FR"},
+			jwt.AmrKey:             []string{"pwd", "otp"},
+			jwt.AccessTokenHashKey: atHash,
+			jwt.CodeHashKey:        cHash,
+		}
+	}
+
+	testcases := []struct {
+		Name   string
+		Alg    jwa.SignatureAlgorithm
+		Modify func(map[string]interface{})
+		Claim  string
+	}{
+		{Name: "valid", Alg: jwa.HS256},
+		{Name: "valid with SHA-384", Alg: jwa.HS384},
+		{Name: "missing iat", Alg: jwa.HS256, Modify: func(c map[string]interface{}) { delete(c, jwt.IssuedAtKey) }, Claim: jwt.IssuedAtKey},
+		{Name: "wrong audience", Alg: jwa.HS256, Modify: func(c map[string]interface{}) { c[jwt.AudienceKey] = []string{"other"} }, Claim: jwt.AudienceKey},
+		{Name: "multiple audiences without azp", Alg: jwa.HS256, Modify: func(c map[string]interface{}) { c[jwt.AudienceKey] = []string{"client", "other"} }, Claim: jwt.AuthorizedPartyKey},
+		{Name: "multiple audiences with azp", Alg: jwa.HS256, Modify: func(c map[string]interface{}) {
+			c[jwt.AudienceKey] = []string{"client", "other"}
+			c[jwt.AuthorizedPartyKey] = "client"
+		}},
+		{Name: "wrong azp", Alg: jwa.HS256, Modify: func(c map[string]interface{}) { c[jwt.AuthorizedPartyKey] = "other" }, Claim: jwt.AuthorizedPartyKey},
+		{Name: "wrong nonce", Alg: jwa.HS256, Modify: func(c map[string]interface{}) { c[jwt.NonceKey] = "replayed" }, Claim: jwt.NonceKey},
+		{Name: "missing auth_time", Alg: jwa.HS256, Modify: func(c map[string]interface{}) { delete(c, jwt.AuthTimeKey) }, Claim: jwt.AuthTimeKey},
+		{Name: "auth_time too old", Alg: jwa.HS256, Modify: func(c map[string]interface{}) { c[jwt.AuthTimeKey] = time.Now().Add(-time.Hour).Unix() }, Claim: jwt.AuthTimeKey},
+		{Name: "wrong at_hash", Alg: jwa.HS256, Modify: func(c map[string]interface{}) { c[jwt.AccessTokenHashKey] = "aUAkJG-u6x4RTWuILWy-CA" }, Claim: jwt.AccessTokenHashKey},
+		{Name: "c_hash of another alg", Alg: jwa.HS384, Modify: func(c map[string]interface{}) {
+			cHash, _ := jwt.TokenHash(code, jwa.HS256)
+			c[jwt.CodeHashKey] = cHash
+		}, Claim: jwt.CodeHashKey},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			c := claims(tc.Alg)
+			if tc.Modify != nil {
+				tc.Modify(c)
+			}
+			tok := jwt.New()
+			for name, value := range c {
+				if err := tok.Set(name, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			signed, err := jwt.Sign(tok, tc.Alg, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, err := jwt.ParseBytes(signed, jwt.WithVerify(tc.Alg, key), jwt.WithIDTokenProfile("https://issuer.example.com", "client",
+				jwt.WithNonce("n-0S6_WzA2Mj"),
+				jwt.WithMaxAge(10*time.Minute),
+				jwt.WithAccessToken(accessToken),
+				jwt.WithAuthorizationCode(code),
+			))
+			if len(tc.Claim) > 0 {
+				var claimErr *jwt.InvalidClaimError
+				if !errors.As(err, &claimErr) || claimErr.Claim() != tc.Claim {
+					t.Errorf("expected invalid %s claim, got %v", tc.Claim, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			idToken := jwt.IDToken{Token: parsed}
+			if idToken.Email() != "alice@example.com" || !idToken.EmailVerified() {
+				t.Errorf("unexpected email claims: %#v, %t", idToken.Email(), idToken.EmailVerified())
+			}
+			if address := idToken.Address(); address == nil || address.Locality != "Paris" || address.Country != "FR" {
+				t.Errorf("unexpected address claim: %#v", address)
+			}
+			if amr := idToken.AuthenticationMethodsReference(); len(amr) != 2 || amr[1] != "otp" {
+				t.Errorf("unexpected amr claim: %#v", amr)
+			}
+			if idToken.AuthTime().IsZero() {
+				t.Error("expected auth_time claim")
+			}
+		})
+	}
+
+	// test vector from OpenID Connect Core 1.0, Appendix A.3
+	if atHash, _ := jwt.TokenHash(accessToken, jwa.RS256); atHash != "77QmUPtjPfzWtF2AnpK9RQ" {
+		t.Errorf("unexpected at_hash %s", atHash)
+	}
+}
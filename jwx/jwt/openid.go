@@ -0,0 +1,356 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	jwa2 "github.com/whlanuo/traefik-jwt-middleware/jwx/jwa"
+	types2 "github.com/whlanuo/traefik-jwt-middleware/jwx/jwt/internal/types"
+	"github.com/whlanuo/traefik-jwt-middleware/option"
+
+	// registers the hash functions used by at_hash and c_hash
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// Claims defined by OpenID Connect Core 1.0 for ID tokens, in addition to
+// the registered claims
+const (
+	EmailKey           = "email"
+	EmailVerifiedKey   = "email_verified"
+	NameKey            = "name"
+	PictureKey         = "picture"
+	AddressKey         = "address"
+	AuthTimeKey        = "auth_time"
+	NonceKey           = "nonce"
+	AuthorizedPartyKey = "azp"
+	AcrKey             = "acr"
+	AmrKey             = "amr"
+	AccessTokenHashKey = "at_hash"
+	CodeHashKey        = "c_hash"
+)
+
+// IDToken provides typed accessors for the claims of an OpenID Connect ID
+// token. Claims that are missing or of an unexpected type are returned as
+// zero values.
+type IDToken struct {
+	Token
+}
+
+// Address is the "address" claim of an ID token, as defined in OpenID
+// Connect Core 1.0 section 5.1.1
+type Address struct {
+	Formatted     string
+	StreetAddress string
+	Locality      string
+	Region        string
+	PostalCode    string
+	Country       string
+}
+
+// Email returns the value of the "email" claim
+func (t IDToken) Email() string {
+	return t.stringClaim(EmailKey)
+}
+
+// EmailVerified returns the value of the "email_verified" claim
+func (t IDToken) EmailVerified() bool {
+	v, _ := t.Get(EmailVerifiedKey)
+	b, _ := v.(bool)
+	return b
+}
+
+// Name returns the value of the "name" claim
+func (t IDToken) Name() string {
+	return t.stringClaim(NameKey)
+}
+
+// Picture returns the value of the "picture" claim
+func (t IDToken) Picture() string {
+	return t.stringClaim(PictureKey)
+}
+
+// Address returns the value of the "address" claim, or nil if missing
+func (t IDToken) Address() *Address {
+	v, _ := t.Get(AddressKey)
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	get := func(name string) string {
+		s, _ := m[name].(string)
+		return s
+	}
+	return &Address{
+		Formatted:     get("formatted"),
+		StreetAddress: get("street_address"),
+		Locality:      get("locality"),
+		Region:        get("region"),
+		PostalCode:    get("postal_code"),
+		Country:       get("country"),
+	}
+}
+
+// AuthTime returns the value of the "auth_time" claim
+func (t IDToken) AuthTime() time.Time {
+	v, ok := t.Get(AuthTimeKey)
+	if !ok {
+		return time.Time{}
+	}
+	var d types2.NumericDate
+	if err := d.Accept(v); err != nil {
+		return time.Time{}
+	}
+	return d.Get()
+}
+
+// Nonce returns the value of the "nonce" claim
+func (t IDToken) Nonce() string {
+	return t.stringClaim(NonceKey)
+}
+
+// AuthorizedParty returns the value of the "azp" claim
+func (t IDToken) AuthorizedParty() string {
+	return t.stringClaim(AuthorizedPartyKey)
+}
+
+// AuthenticationContextClassReference returns the value of the "acr" claim
+func (t IDToken) AuthenticationContextClassReference() string {
+	return t.stringClaim(AcrKey)
+}
+
+// AuthenticationMethodsReference returns the value of the "amr" claim
+func (t IDToken) AuthenticationMethodsReference() []string {
+	v, ok := t.Get(AmrKey)
+	if !ok {
+		return nil
+	}
+	var l types2.StringList
+	if err := l.Accept(v); err != nil {
+		return nil
+	}
+	return l.Get()
+}
+
+// AccessTokenHash returns the value of the "at_hash" claim
+func (t IDToken) AccessTokenHash() string {
+	return t.stringClaim(AccessTokenHashKey)
+}
+
+// CodeHash returns the value of the "c_hash" claim
+func (t IDToken) CodeHash() string {
+	return t.stringClaim(CodeHashKey)
+}
+
+func (t IDToken) stringClaim(name string) string {
+	v, _ := t.Get(name)
+	s, _ := v.(string)
+	return s
+}
+
+// IDTokenOption is an option for WithIDTokenProfile
+type IDTokenOption interface {
+	Option
+	isIDTokenOption()
+}
+
+type idTokenOption struct {
+	Option
+}
+
+func (o *idTokenOption) isIDTokenOption() {}
+
+func newIDTokenOption(n interface{}, v interface{}) IDTokenOption {
+	return &idTokenOption{Option: option.New(n, v)}
+}
+
+// WithNonce requires the "nonce" claim of the ID token to be the nonce
+// sent in the authentication request
+func WithNonce(nonce string) IDTokenOption {
+	return newIDTokenOption(identNonce{}, nonce)
+}
+
+// WithMaxAge requires the "auth_time" claim of the ID token to be present
+// and no older than the given duration, as when the "max_age" parameter
+// was sent in the authentication request
+func WithMaxAge(d time.Duration) IDTokenOption {
+	return newIDTokenOption(identMaxAge{}, d)
+}
+
+// WithAccessToken requires the "at_hash" claim of the ID token, if present,
+// to match the access token issued along with it
+func WithAccessToken(accessToken string) IDTokenOption {
+	return newIDTokenOption(identAccessToken{}, accessToken)
+}
+
+// WithAuthorizationCode requires the "c_hash" claim of the ID token, if
+// present, to match the authorization code issued along with it
+func WithAuthorizationCode(code string) IDTokenOption {
+	return newIDTokenOption(identAuthorizationCode{}, code)
+}
+
+type idTokenProfile struct {
+	issuer      string
+	clientID    string
+	nonce       *string
+	maxAge      *time.Duration
+	accessToken *string
+	code        *string
+}
+
+// WithIDTokenProfile is passed to `Parse()` method to validate the token as
+// an OpenID Connect ID token, as described in OpenID Connect Core 1.0
+// section 3.1.3.7:
+//
+//   - the "iss", "sub", "aud", "exp" and "iat" claims must be present
+//   - "iss" must be the given issuer, and "aud" must contain the given
+//     client ID
+//   - "azp" must be present if there are multiple audiences, and must be the
+//     client ID if present
+//
+// Nonce, max age and the hashes of the access token and authorization code
+// are checked when given as options. The hashes are computed with the hash
+// function of the "alg" header of the token.
+//
+// The time based claims are validated by `Validate()` as usual, which is
+// enabled with `WithValidate(true)`. The clock and skew given via
+// `WithClock` and `WithAcceptableSkew` are also used for the max age.
+func WithIDTokenProfile(issuer, clientID string, options ...IDTokenOption) ParseOption {
+	p := &idTokenProfile{
+		issuer:   issuer,
+		clientID: clientID,
+	}
+	for _, o := range options {
+		switch o.Ident() {
+		case identNonce{}:
+			v := o.Value().(string)
+			p.nonce = &v
+		case identMaxAge{}:
+			v := o.Value().(time.Duration)
+			p.maxAge = &v
+		case identAccessToken{}:
+			v := o.Value().(string)
+			p.accessToken = &v
+		case identAuthorizationCode{}:
+			v := o.Value().(string)
+			p.code = &v
+		}
+	}
+	return newParseOption(identIDTokenProfile{}, p)
+}
+
+func (p *idTokenProfile) validate(t Token, alg jwa2.SignatureAlgorithm, options ...Option) error {
+	var clock Clock = ClockFunc(time.Now)
+	var skew time.Duration
+	for _, o := range options {
+		switch o.Ident() {
+		case identClock{}:
+			clock = o.Value().(Clock)
+		case identAcceptableSkew{}:
+			skew = o.Value().(time.Duration)
+		}
+	}
+
+	for _, name := range []string{IssuerKey, SubjectKey, AudienceKey, ExpirationKey, IssuedAtKey} {
+		if _, ok := t.Get(name); !ok {
+			return &InvalidClaimError{claim: name, reason: `required by the ID token profile`, missing: true}
+		}
+	}
+
+	if t.Issuer() != p.issuer {
+		return &InvalidClaimError{claim: IssuerKey, reason: fmt.Sprintf(`expected %#v`, p.issuer)}
+	}
+
+	var found bool
+	for _, aud := range t.Audience() {
+		if aud == p.clientID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return &InvalidClaimError{claim: AudienceKey, reason: fmt.Sprintf(`expected to contain %#v`, p.clientID)}
+	}
+
+	idToken := IDToken{Token: t}
+	if v, ok := t.Get(AuthorizedPartyKey); ok {
+		if azp, _ := v.(string); azp != p.clientID {
+			return &InvalidClaimError{claim: AuthorizedPartyKey, reason: fmt.Sprintf(`expected %#v`, p.clientID)}
+		}
+	} else if len(t.Audience()) > 1 {
+		return &InvalidClaimError{claim: AuthorizedPartyKey, reason: `required with multiple audiences`, missing: true}
+	}
+
+	if p.nonce != nil {
+		if subtle.ConstantTimeCompare([]byte(idToken.Nonce()), []byte(*p.nonce)) != 1 {
+			return &InvalidClaimError{claim: NonceKey, reason: `does not match`}
+		}
+	}
+
+	if p.maxAge != nil {
+		authTime := idToken.AuthTime()
+		if authTime.IsZero() {
+			return &InvalidClaimError{claim: AuthTimeKey, reason: `required with max age`, missing: true}
+		}
+		if clock.Now().Truncate(time.Second).Add(-skew).After(authTime.Add(*p.maxAge)) {
+			return &InvalidClaimError{claim: AuthTimeKey, reason: `exceeds max age`}
+		}
+	}
+
+	if p.accessToken != nil {
+		if err := checkTokenHash(idToken.AccessTokenHash(), *p.accessToken, alg); err != nil {
+			return &InvalidClaimError{claim: AccessTokenHashKey, reason: err.Error()}
+		}
+	}
+	if p.code != nil {
+		if err := checkTokenHash(idToken.CodeHash(), *p.code, alg); err != nil {
+			return &InvalidClaimError{claim: CodeHashKey, reason: err.Error()}
+		}
+	}
+	return nil
+}
+
+// checkTokenHash verifies the at_hash or c_hash claim, if present, against
+// the given value
+func checkTokenHash(claim, value string, alg jwa2.SignatureAlgorithm) error {
+	if len(claim) == 0 {
+		return nil
+	}
+
+	expected, err := TokenHash(value, alg)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(claim), []byte(expected)) != 1 {
+		return errors.New(`does not match`)
+	}
+	return nil
+}
+
+// TokenHash computes the value of the "at_hash" or "c_hash" claim for the
+// given access token or authorization code: the base64url encoding of the
+// left-most half of its hash, using the hash function of the given
+// signature algorithm.
+func TokenHash(value string, alg jwa2.SignatureAlgorithm) (string, error) {
+	var hash crypto.Hash
+	switch alg {
+	case jwa2.HS256, jwa2.RS256, jwa2.PS256, jwa2.ES256:
+		hash = crypto.SHA256
+	case jwa2.HS384, jwa2.RS384, jwa2.PS384, jwa2.ES384:
+		hash = crypto.SHA384
+	case jwa2.HS512, jwa2.RS512, jwa2.PS512, jwa2.ES512, jwa2.EdDSA:
+		hash = crypto.SHA512
+	default:
+		return "", fmt.Errorf(`unsupported algorithm %#v`, alg.String())
+	}
+
+	h := hash.New()
+	_, _ = h.Write([]byte(value))
+	sum := h.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]), nil
+}
@@ -12,16 +12,26 @@ import (
 type Option = option.Interface
 
 type identAcceptableSkew struct{}
+type identAccessToken struct{}
+type identAccessTokenProfile struct{}
 type identAudience struct{}
+type identAuthorizationCode struct{}
 type identClaim struct{}
 type identClock struct{}
 type identDefault struct{}
 type identHeaders struct{}
+type identIDTokenProfile struct{}
+type identKeyAcceptor struct{}
+type identKeyUsed struct{}
 type identIssuer struct{}
 type identJwtid struct{}
 type identKeySet struct{}
+type identMaxAge struct{}
+type identNonce struct{}
 type identSubject struct{}
 type identToken struct{}
+type identTryAllKeys struct{}
+type identTypeHeader struct{}
 type identValidate struct{}
 type identVerify struct{}
 
@@ -99,6 +109,43 @@ func UseDefaultKey(value bool) ParseOption {
 	return newParseOption(identDefault{}, value)
 }
 
+// TryAllKeys is used in conjunction with the option WithKeySet to
+// instruct the Parse method to try every candidate key in the key set
+// until one of them verifies the JWT. Candidates are the keys matching
+// the Key ID of the JWT, or all keys if the JWT has no Key ID, whose
+// key type and algorithm are compatible with the "alg" header.
+//
+// This is useful for issuers that omit the Key ID, or key sets that
+// contain multiple keys with the same Key ID.
+func TryAllKeys(value bool) ParseOption {
+	return newParseOption(identTryAllKeys{}, value)
+}
+
+// WithKeyAcceptor is used in conjunction with the option WithKeySet
+// to decide which keys in the key set may be used for verification.
+// By default jws.DefaultJWKAcceptor is used, which rejects keys whose
+// "use" is "enc" or whose "key_ops" lacks "verify". Pass
+// jws.PermissiveJWKAcceptor to opt out of these checks.
+func WithKeyAcceptor(acceptor jws2.JWKAcceptor) ParseOption {
+	return newParseOption(identKeyAcceptor{}, acceptor)
+}
+
+// WithKeyUsed is used in conjunction with the option WithKeySet to
+// retrieve the key of the key set that verified the JWT. The key is
+// stored in dst once the JWT was successfully parsed.
+func WithKeyUsed(dst *jwk2.Key) ParseOption {
+	return newParseOption(identKeyUsed{}, dst)
+}
+
+// WithTypeHeader is passed to `Parse()` method to require the "typ"
+// header of the JWT to be the given media type, such as "at+jwt" for
+// access tokens (RFC 9068). As defined in RFC 7515, the comparison is case
+// insensitive, and the "application/" prefix may be omitted. When given
+// multiple times, any of the given types is accepted.
+func WithTypeHeader(typ string) ParseOption {
+	return newParseOption(identTypeHeader{}, typ)
+}
+
 // WithToken specifies the token instance that is used when parsing
 // JWT tokens.
 func WithToken(t Token) ParseOption {
@@ -130,8 +177,9 @@ func WithAcceptableSkew(dur time.Duration) ValidateOption {
 	return newValidateOption(identAcceptableSkew{}, dur)
 }
 
-// WithIssuer specifies that expected issuer value. If not specified,
-// the value of issuer is not verified at all.
+// WithIssuer specifies that expected issuer value. Tokens without an
+// issuer are rejected. If not specified, the value of issuer is not
+// verified at all.
 func WithIssuer(s string) ValidateOption {
 	return newValidateOption(identIssuer{}, s)
 }
@@ -150,8 +198,8 @@ func WithJwtID(s string) ValidateOption {
 
 // WithAudience specifies that expected audience value.
 // Verify will return true if one of the values in the `aud` element
-// matches this value.  If not specified, the value of issuer is not
-// verified at all.
+// matches this value, and tokens without an audience are rejected.
+// If not specified, the value of audience is not verified at all.
 func WithAudience(s string) ValidateOption {
 	return newValidateOption(identAudience{}, s)
 }
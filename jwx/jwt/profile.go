@@ -0,0 +1,156 @@
+package jwt
+
+import (
+	"fmt"
+)
+
+// Claims defined by RFC 9068 for JWT access tokens, in addition to the
+// registered claims
+const (
+	ClientIDKey     = "client_id"
+	ScopeKey        = "scope"
+	GroupsKey       = "groups"
+	RolesKey        = "roles"
+	EntitlementsKey = "entitlements"
+)
+
+const accessTokenType = "at+jwt"
+
+// InvalidClaimError is returned by Parse when a claim required by a
+// profile such as WithAccessTokenProfile is missing or malformed
+type InvalidClaimError struct {
+	claim   string
+	reason  string
+	missing bool
+}
+
+// Claim returns the name of the invalid claim
+func (e *InvalidClaimError) Claim() string {
+	return e.claim
+}
+
+// Missing reports whether the claim is absent, rather than present with an
+// unexpected value
+func (e *InvalidClaimError) Missing() bool {
+	return e.missing
+}
+
+func (e *InvalidClaimError) Error() string {
+	return fmt.Sprintf(`invalid %s claim: %s`, e.claim, e.reason)
+}
+
+type accessTokenProfile struct {
+	issuer   string
+	audience string
+}
+
+// WithAccessTokenProfile is passed to `Parse()` method to validate the
+// token as a JWT access token, as defined in RFC 9068:
+//
+//   - the "typ" header must be "at+jwt" (or "application/at+jwt")
+//   - the "iss", "exp", "aud", "sub", "client_id", "iat" and "jti" claims
+//     must be present
+//   - "iss" must be the given issuer, and "aud" must contain the given audience
+//   - "scope" must be a space separated list of scope tokens (RFC 6749)
+//   - "groups", "roles" and "entitlements" must be lists of strings (RFC 7643)
+//
+// The time based claims are validated by `Validate()` as usual, which is
+// enabled with `WithValidate(true)`.
+func WithAccessTokenProfile(issuer, audience string) ParseOption {
+	return newParseOption(identAccessTokenProfile{}, &accessTokenProfile{
+		issuer:   issuer,
+		audience: audience,
+	})
+}
+
+func (p *accessTokenProfile) validate(t Token) error {
+	for _, name := range []string{IssuerKey, ExpirationKey, AudienceKey, SubjectKey, ClientIDKey, IssuedAtKey, JwtIDKey} {
+		if _, ok := t.Get(name); !ok {
+			return &InvalidClaimError{claim: name, reason: `required by the access token profile`, missing: true}
+		}
+	}
+
+	if t.Issuer() != p.issuer {
+		return &InvalidClaimError{claim: IssuerKey, reason: fmt.Sprintf(`expected %#v`, p.issuer)}
+	}
+
+	var found bool
+	for _, aud := range t.Audience() {
+		if aud == p.audience {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return &InvalidClaimError{claim: AudienceKey, reason: fmt.Sprintf(`expected to contain %#v`, p.audience)}
+	}
+
+	if v, _ := t.Get(ClientIDKey); !isNonEmptyString(v) {
+		return &InvalidClaimError{claim: ClientIDKey, reason: `expected a non-empty string`}
+	}
+
+	if v, ok := t.Get(ScopeKey); ok {
+		scope, ok := v.(string)
+		if !ok || !isValidScope(scope) {
+			return &InvalidClaimError{claim: ScopeKey, reason: `expected a space separated list of scope tokens`}
+		}
+	}
+
+	for _, name := range []string{GroupsKey, RolesKey, EntitlementsKey} {
+		if v, ok := t.Get(name); ok && !isStringList(v) {
+			return &InvalidClaimError{claim: name, reason: `expected a list of strings`}
+		}
+	}
+	return nil
+}
+
+func isNonEmptyString(v interface{}) bool {
+	s, ok := v.(string)
+	return ok && len(s) > 0
+}
+
+// isValidScope reports whether the value is a list of scope tokens, as
+// defined in RFC 6749 section 3.3, separated by single spaces
+func isValidScope(scope string) bool {
+	if len(scope) == 0 {
+		return false
+	}
+
+	var inToken bool
+	for i := 0; i < len(scope); i++ {
+		c := scope[i]
+		switch {
+		case c == ' ':
+			if !inToken {
+				return false
+			}
+			inToken = false
+		case c == 0x21 || (c >= 0x23 && c <= 0x5B) || (c >= 0x5D && c <= 0x7E):
+			inToken = true
+		default:
+			return false
+		}
+	}
+	return inToken
+}
+
+func isStringList(v interface{}) bool {
+	switch v := v.(type) {
+	case []string:
+		for _, s := range v {
+			if len(s) == 0 {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		for _, e := range v {
+			if !isNonEmptyString(e) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
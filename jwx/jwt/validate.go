@@ -6,6 +6,22 @@ import (
 	"time"
 )
 
+// Errors returned by Validate when the time based claims of a token are not
+// satisfied by the current time
+var (
+	ErrTokenExpired     = errors.New(`exp not satisfied`)
+	ErrInvalidIssuedAt  = errors.New(`iat not satisfied`)
+	ErrTokenNotYetValid = errors.New(`nbf not satisfied`)
+)
+
+// Errors returned by Validate when the claims of a token do not match the
+// values given via WithIssuer and WithAudience. A token missing either claim
+// fails with an *InvalidClaimError instead
+var (
+	ErrInvalidIssuer   = errors.New(`iss not satisfied`)
+	ErrInvalidAudience = errors.New(`aud not satisfied`)
+)
+
 type Clock interface {
 	Now() time.Time
 }
@@ -56,8 +72,12 @@ func Validate(t Token, options ...ValidateOption) error {
 
 	// check for iss
 	if len(issuer) > 0 {
-		if v := t.Issuer(); v != "" && v != issuer {
-			return errors.New(`iss not satisfied`)
+		v := t.Issuer()
+		if v == "" {
+			return &InvalidClaimError{claim: IssuerKey, reason: `required with an issuer`, missing: true}
+		}
+		if v != issuer {
+			return ErrInvalidIssuer
 		}
 	}
 
@@ -77,6 +97,9 @@ func Validate(t Token, options ...ValidateOption) error {
 
 	// check for aud
 	if len(audience) > 0 {
+		if len(t.Audience()) == 0 {
+			return &InvalidClaimError{claim: AudienceKey, reason: `required with an audience`, missing: true}
+		}
 		var found bool
 		for _, v := range t.Audience() {
 			if v == audience {
@@ -85,7 +108,7 @@ func Validate(t Token, options ...ValidateOption) error {
 			}
 		}
 		if !found {
-			return errors.New(`aud not satisfied`)
+			return ErrInvalidAudience
 		}
 	}
 
@@ -94,7 +117,7 @@ func Validate(t Token, options ...ValidateOption) error {
 		now := clock.Now().Truncate(time.Second)
 		ttv := tv.Truncate(time.Second)
 		if !now.Before(ttv.Add(skew)) {
-			return errors.New(`exp not satisfied`)
+			return ErrTokenExpired
 		}
 	}
 
@@ -103,7 +126,7 @@ func Validate(t Token, options ...ValidateOption) error {
 		now := clock.Now().Truncate(time.Second)
 		ttv := tv.Truncate(time.Second)
 		if now.Before(ttv.Add(-1 * skew)) {
-			return errors.New(`iat not satisfied`)
+			return ErrInvalidIssuedAt
 		}
 	}
 
@@ -113,7 +136,7 @@ func Validate(t Token, options ...ValidateOption) error {
 		ttv := tv.Truncate(time.Second)
 		// now cannot be before t, so we check for now > t - skew
 		if !now.After(ttv.Add(-1 * skew)) {
-			return errors.New(`nbf not satisfied`)
+			return ErrTokenNotYetValid
 		}
 	}
 
@@ -0,0 +1,228 @@
+package traefik_jwt_middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwk"
+)
+
+const (
+	defaultJwksRefetchInterval   = 10 * time.Second
+	jwksFetchTimeout             = 10 * time.Second
+	defaultKeyFileReloadInterval = 5 * time.Second
+)
+
+// keySource provides the key set used to verify tokens
+type keySource interface {
+	KeySet(ctx context.Context) (*jwk.Set, error)
+}
+
+// refresher is implemented by key sources that can be re-fetched on demand,
+// for example when a token refers to a key ID that is not in the current set
+type refresher interface {
+	Refresh(ctx context.Context) (*jwk.Set, error)
+}
+
+// staticKeySet is a key set that never changes, such as PEM keys from Config
+type staticKeySet struct {
+	set *jwk.Set
+}
+
+func (s *staticKeySet) KeySet(ctx context.Context) (*jwk.Set, error) {
+	return s.set, nil
+}
+
+// fetchCall is a fetch of the remote key set that is in progress. Concurrent
+// callers wait for it instead of starting their own.
+type fetchCall struct {
+	done chan struct{}
+	set  *jwk.Set
+	err  error
+}
+
+// remoteKeySet is a key set fetched from a remote JWKS endpoint. It is
+// fetched on first use, and re-fetched via Refresh at most once per
+// minInterval.
+type remoteKeySet struct {
+	url         string
+	client      *http.Client
+	minInterval time.Duration
+	metrics     *metrics
+
+	mu        sync.Mutex
+	set       *jwk.Set
+	lastFetch time.Time
+	inflight  *fetchCall
+}
+
+func newRemoteKeySet(url string, minInterval time.Duration, m *metrics) *remoteKeySet {
+	return &remoteKeySet{
+		url:         url,
+		client:      &http.Client{Timeout: jwksFetchTimeout},
+		minInterval: minInterval,
+		metrics:     m,
+	}
+}
+
+// KeySet returns the cached key set, fetching it if it was never fetched
+func (r *remoteKeySet) KeySet(ctx context.Context) (*jwk.Set, error) {
+	r.mu.Lock()
+	set := r.set
+	r.mu.Unlock()
+
+	if set != nil {
+		return set, nil
+	}
+	return r.Refresh(ctx)
+}
+
+// Refresh re-fetches the key set. Concurrent calls share a single fetch,
+// and fetches are not started more often than once per minInterval. When
+// rate limited, the current key set is returned as is, or an error if no
+// fetch succeeded yet.
+func (r *remoteKeySet) Refresh(ctx context.Context) (*jwk.Set, error) {
+	r.mu.Lock()
+	if call := r.inflight; call != nil {
+		r.mu.Unlock()
+		return call.wait(ctx)
+	}
+
+	if !r.lastFetch.IsZero() && time.Since(r.lastFetch) < r.minInterval {
+		set := r.set
+		r.mu.Unlock()
+		if set == nil {
+			return nil, errors.New("remote key set is not available")
+		}
+		return set, nil
+	}
+
+	call := &fetchCall{done: make(chan struct{})}
+	r.inflight = call
+	r.lastFetch = time.Now()
+	r.mu.Unlock()
+
+	go r.fetch(call)
+	return call.wait(ctx)
+}
+
+// fetch runs detached from the request that triggered it, so that a
+// cancelled request does not fail the fetch for everyone waiting on it
+func (r *remoteKeySet) fetch(call *fetchCall) {
+	ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+	defer cancel()
+
+	call.set, call.err = jwk.FetchHTTPWithContext(ctx, r.url, jwk.WithHTTPClient(r.client))
+	r.metrics.Refreshed(sourceJwks, call.err)
+
+	r.mu.Lock()
+	if call.err == nil {
+		r.set = call.set
+	}
+	r.inflight = nil
+	r.mu.Unlock()
+
+	close(call.done)
+}
+
+func (c *fetchCall) wait(ctx context.Context) (*jwk.Set, error) {
+	select {
+	case <-c.done:
+		return c.set, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fileKeySet is a key set read from a local JWKS or PEM file, such as a
+// mounted Kubernetes secret. The file is checked for changes at most once
+// per interval, and the parsed key set is swapped when it changed. Updates
+// that fail to parse are ignored, keeping the last good key set, and counted
+// as failed refreshes. The file is read and parsed without holding the lock,
+// by a single caller at a time, while others keep using the current key set.
+type fileKeySet struct {
+	metrics *metrics
+
+	mu        sync.Mutex
+	file      watchedFile
+	set       *jwk.Set
+	reloading bool
+}
+
+// newFileKeySet reads the key set from the given file. Unlike later
+// reloads, failing to read the initial key set is an error.
+func newFileKeySet(path string, interval time.Duration, m *metrics) (*fileKeySet, error) {
+	f := &fileKeySet{metrics: m, file: watchedFile{path: path, interval: interval}}
+	set, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	f.set = set
+	return f, nil
+}
+
+// KeySet returns the current key set, reloading the file first if it
+// changed since it was last checked
+func (f *fileKeySet) KeySet(ctx context.Context) (*jwk.Set, error) {
+	f.mu.Lock()
+	if f.reloading || !f.file.due() {
+		set := f.set
+		f.mu.Unlock()
+		return set, nil
+	}
+	// the file is only accessed by the reloading caller until it is done
+	f.reloading = true
+	f.mu.Unlock()
+
+	set, err := f.load()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil && set != nil {
+		f.set = set
+	}
+	f.reloading = false
+	return f.set, nil
+}
+
+// load reads and parses the file, returning nil if it did not change
+func (f *fileKeySet) load() (*jwk.Set, error) {
+	buf, err := f.file.read()
+	if buf == nil {
+		if err != nil {
+			f.metrics.Refreshed(sourceFile, err)
+		}
+		return nil, err
+	}
+
+	set, err := parseKeySet(buf)
+	f.metrics.Refreshed(sourceFile, err)
+	if err != nil {
+		return nil, err
+	}
+	f.file.accept()
+	return set, nil
+}
+
+// parseKeySet parses either a JWKS (or single JWK) in JSON format, or one
+// or more PEM encoded keys
+func parseKeySet(buf []byte) (*jwk.Set, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(buf), []byte("{")) {
+		set, err := jwk.ParseBytes(buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+		}
+		return set, nil
+	}
+
+	set, err := jwk.ParsePEM(buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key PEM: %w", err)
+	}
+	return set, nil
+}
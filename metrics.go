@@ -0,0 +1,266 @@
+package traefik_jwt_middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jws"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwt"
+)
+
+// Reasons for denying a request, used as metric labels
+const (
+	reasonMissing       = "missing"
+	reasonMalformed     = "malformed"
+	reasonBadSignature  = "bad_signature"
+	reasonExpired       = "expired"
+	reasonUnknownKid    = "unknown_kid"
+	reasonForbidden     = "forbidden"
+	reasonInvalidClaims = "invalid_claims"
+	reasonRevoked       = "revoked"
+	reasonReplayed      = "replayed"
+	reasonInvalidProof  = "invalid_proof"
+	reasonInactive      = "inactive"
+	reasonError         = "error"
+)
+
+// denyReasons are all reasons, so that every series is exported even
+// before it was first incremented
+var denyReasons = []string{
+	reasonMissing,
+	reasonMalformed,
+	reasonBadSignature,
+	reasonExpired,
+	reasonUnknownKid,
+	reasonForbidden,
+	reasonInvalidClaims,
+	reasonRevoked,
+	reasonReplayed,
+	reasonInvalidProof,
+	reasonInactive,
+	reasonError,
+}
+
+// Key set and revocation list sources, and refresh results, used as metric
+// labels
+const (
+	sourceJwks           = "jwks"
+	sourceFile           = "file"
+	sourceRevocationFile = "revocation_file"
+
+	refreshSuccess = "success"
+	refreshFailure = "failure"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the verification
+// latency histogram
+var latencyBuckets = []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+
+// metrics collects the metrics of a middleware instance, and serves them in
+// the Prometheus text exposition format
+type metrics struct {
+	name  string
+	cache *tokenCache
+
+	mu            sync.Mutex
+	allowed       uint64
+	denied        map[string]uint64
+	reported      map[string]uint64
+	latencyCounts []uint64
+	latencySum    float64
+	latencyCount  uint64
+	refreshes     map[refreshKey]uint64
+	lastRefresh   map[refreshKey]time.Time
+}
+
+type refreshKey struct {
+	source string
+	result string
+}
+
+func newMetrics(name string) *metrics {
+	return &metrics{
+		name:          name,
+		denied:        make(map[string]uint64),
+		reported:      make(map[string]uint64),
+		latencyCounts: make([]uint64, len(latencyBuckets)),
+		refreshes:     make(map[refreshKey]uint64),
+		lastRefresh:   make(map[refreshKey]time.Time),
+	}
+}
+
+// setCache Sets the verified token cache whose usage is exported, or nil
+func (m *metrics) setCache(cache *tokenCache) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cache = cache
+}
+
+// Allow records an allowed request
+func (m *metrics) Allow() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.allowed++
+}
+
+// Deny records a request denied for the given reason
+func (m *metrics) Deny(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.denied[reason]++
+}
+
+// Report records a request that would have been denied for the given
+// reason, but was forwarded because the reason is only reported
+func (m *metrics) Report(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reported[reason]++
+}
+
+// ObserveVerification records the time taken to verify a token
+func (m *metrics) ObserveVerification(d time.Duration) {
+	seconds := d.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			m.latencyCounts[i]++
+		}
+	}
+	m.latencySum += seconds
+	m.latencyCount++
+}
+
+// Refreshed records a refresh of the key set or revocation list from the
+// given source
+func (m *metrics) Refreshed(source string, err error) {
+	key := refreshKey{source: source, result: refreshSuccess}
+	if err != nil {
+		key.result = refreshFailure
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refreshes[key]++
+	m.lastRefresh[key] = time.Now()
+}
+
+func (m *metrics) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = res.Write(m.expose())
+}
+
+// expose renders the metrics in the Prometheus text exposition format
+func (m *metrics) expose() []byte {
+	var buf bytes.Buffer
+	name := escapeLabelValue(m.name)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buf.WriteString("# HELP jwt_requests_total Requests handled by the JWT middleware, by outcome and reason.\n")
+	buf.WriteString("# TYPE jwt_requests_total counter\n")
+	fmt.Fprintf(&buf, "jwt_requests_total{middleware=\"%s\",outcome=\"allowed\",reason=\"none\"} %d\n", name, m.allowed)
+	for _, reason := range denyReasons {
+		fmt.Fprintf(&buf, "jwt_requests_total{middleware=\"%s\",outcome=\"denied\",reason=\"%s\"} %d\n", name, reason, m.denied[reason])
+	}
+	for _, reason := range denyReasons {
+		fmt.Fprintf(&buf, "jwt_requests_total{middleware=\"%s\",outcome=\"reported\",reason=\"%s\"} %d\n", name, reason, m.reported[reason])
+	}
+
+	buf.WriteString("# HELP jwt_verification_duration_seconds Time taken to verify tokens.\n")
+	buf.WriteString("# TYPE jwt_verification_duration_seconds histogram\n")
+	for i, bound := range latencyBuckets {
+		fmt.Fprintf(&buf, "jwt_verification_duration_seconds_bucket{middleware=\"%s\",le=\"%s\"} %d\n", name, formatFloat(bound), m.latencyCounts[i])
+	}
+	fmt.Fprintf(&buf, "jwt_verification_duration_seconds_bucket{middleware=\"%s\",le=\"+Inf\"} %d\n", name, m.latencyCount)
+	fmt.Fprintf(&buf, "jwt_verification_duration_seconds_sum{middleware=\"%s\"} %s\n", name, formatFloat(m.latencySum))
+	fmt.Fprintf(&buf, "jwt_verification_duration_seconds_count{middleware=\"%s\"} %d\n", name, m.latencyCount)
+
+	keys := make([]refreshKey, 0, len(m.refreshes))
+	for key := range m.refreshes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].source != keys[j].source {
+			return keys[i].source < keys[j].source
+		}
+		return keys[i].result < keys[j].result
+	})
+
+	buf.WriteString("# HELP jwt_keyset_refreshes_total Refreshes of the key set and revocation list, by source and result.\n")
+	buf.WriteString("# TYPE jwt_keyset_refreshes_total counter\n")
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "jwt_keyset_refreshes_total{middleware=\"%s\",source=\"%s\",result=\"%s\"} %d\n", name, key.source, key.result, m.refreshes[key])
+	}
+	buf.WriteString("# HELP jwt_keyset_last_refresh_timestamp_seconds Time of the last refresh of the key set and revocation list, by source and result.\n")
+	buf.WriteString("# TYPE jwt_keyset_last_refresh_timestamp_seconds gauge\n")
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "jwt_keyset_last_refresh_timestamp_seconds{middleware=\"%s\",source=\"%s\",result=\"%s\"} %d\n", name, key.source, key.result, m.lastRefresh[key].Unix())
+	}
+
+	if m.cache != nil {
+		stats := m.cache.Stats()
+		buf.WriteString("# HELP jwt_token_cache_size Verified tokens in the cache.\n")
+		buf.WriteString("# TYPE jwt_token_cache_size gauge\n")
+		fmt.Fprintf(&buf, "jwt_token_cache_size{middleware=\"%s\"} %d\n", name, stats.Size)
+		buf.WriteString("# HELP jwt_token_cache_capacity Maximum number of verified tokens in the cache.\n")
+		buf.WriteString("# TYPE jwt_token_cache_capacity gauge\n")
+		fmt.Fprintf(&buf, "jwt_token_cache_capacity{middleware=\"%s\"} %d\n", name, stats.Capacity)
+		buf.WriteString("# HELP jwt_token_cache_requests_total Lookups in the verified token cache, by result.\n")
+		buf.WriteString("# TYPE jwt_token_cache_requests_total counter\n")
+		fmt.Fprintf(&buf, "jwt_token_cache_requests_total{middleware=\"%s\",result=\"hit\"} %d\n", name, stats.Hits)
+		fmt.Fprintf(&buf, "jwt_token_cache_requests_total{middleware=\"%s\",result=\"miss\"} %d\n", name, stats.Misses)
+	}
+
+	return buf.Bytes()
+}
+
+// denyReason classifies an error returned when verifying the given token
+func denyReason(err error, token string) string {
+	var unknownKeyID *jwt.UnknownKeyIDError
+	var typeHeader *jwt.TypeHeaderError
+	var invalidClaim *jwt.InvalidClaimError
+	switch {
+	case errors.As(err, &unknownKeyID):
+		return reasonUnknownKid
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return reasonExpired
+	case errors.Is(err, errInactiveToken):
+		return reasonInactive
+	case errors.Is(err, errIntrospectionFailed):
+		return reasonError
+	case errors.Is(err, jwt.ErrInvalidIssuer), errors.Is(err, jwt.ErrInvalidAudience),
+		errors.As(err, &invalidClaim) && !invalidClaim.Missing() &&
+			(invalidClaim.Claim() == jwt.IssuerKey || invalidClaim.Claim() == jwt.AudienceKey):
+		// the token is authentic and current, but not meant for this service
+		return reasonForbidden
+	case errors.Is(err, jwt.ErrTokenNotYetValid), errors.Is(err, jwt.ErrInvalidIssuedAt),
+		errors.As(err, &typeHeader), errors.As(err, &invalidClaim):
+		return reasonInvalidClaims
+	}
+
+	if _, parseErr := jws.ParseString(token); parseErr != nil {
+		return reasonMalformed
+	}
+	return reasonBadSignature
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
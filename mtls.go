@@ -0,0 +1,167 @@
+package traefik_jwt_middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwt"
+)
+
+const (
+	// certificateThumbprintKey is the member of the "cnf" claim holding the
+	// SHA-256 thumbprint of the certificate the token is bound to (RFC 8705)
+	certificateThumbprintKey = "x5t#S256"
+
+	// defaultClientCertHeader is the header set by Traefik's
+	// passTLSClientCert middleware
+	defaultClientCertHeader = "X-Forwarded-Tls-Client-Cert"
+)
+
+// errCertificateMismatch is returned when the client certificate does not
+// match the certificate the token is bound to
+var errCertificateMismatch = errors.New("client certificate does not match the token binding")
+
+// trustedProxies are the networks of the proxies whose forwarded headers
+// are trusted, such as the client certificate or the original request URI
+type trustedProxies []*net.IPNet
+
+// parseTrustedProxies Parses the IP addresses or CIDRs of trusted proxies
+func parseTrustedProxies(proxies []string) (trustedProxies, error) {
+	var trusted trustedProxies
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			if strings.Contains(proxy, ":") {
+				proxy += "/128"
+			} else {
+				proxy += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse trusted proxy: %w", err)
+		}
+		trusted = append(trusted, network)
+	}
+	return trusted, nil
+}
+
+// contains Reports whether the remote address is one of the trusted proxies
+func (t trustedProxies) contains(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range t {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// certificateBinding checks that tokens are bound to the client certificate
+// of the mutual TLS connection, as described in RFC 8705 section 3. The
+// certificate is taken from the TLS connection, or from a header set by a
+// trusted proxy that terminated the connection.
+type certificateBinding struct {
+	header  string
+	trusted trustedProxies
+}
+
+func newCertificateBinding(header string, trusted trustedProxies) *certificateBinding {
+	return &certificateBinding{header: header, trusted: trusted}
+}
+
+// check Verifies that the token is bound to the client certificate of the
+// request
+func (b *certificateBinding) check(req *http.Request, tk jwt.Token) error {
+	expected, ok := certificateThumbprint(tk)
+	if !ok {
+		return fmt.Errorf("%w: token is not certificate-bound", errCertificateMismatch)
+	}
+
+	der, err := b.clientCertificate(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errCertificateMismatch, err)
+	}
+
+	sum := sha256.Sum256(der)
+	if subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(expected)) != 1 {
+		return errCertificateMismatch
+	}
+	return nil
+}
+
+// clientCertificate Returns the DER encoded client certificate of the TLS
+// connection, or the one forwarded by a trusted proxy
+func (b *certificateBinding) clientCertificate(req *http.Request) ([]byte, error) {
+	if req.TLS != nil && len(req.TLS.PeerCertificates) > 0 {
+		return req.TLS.PeerCertificates[0].Raw, nil
+	}
+
+	if len(b.header) == 0 || !b.trusted.contains(req.RemoteAddr) {
+		return nil, errors.New("no client certificate")
+	}
+	value := req.Header.Get(b.header)
+	if len(value) == 0 {
+		return nil, errors.New("no client certificate")
+	}
+	return parseForwardedCertificate(value)
+}
+
+// parseForwardedCertificate Parses the leaf certificate from a forwarded
+// client certificate header. The value may be URL encoded, and is either a
+// PEM block, or its base64 content without delimiters as set by Traefik. Only
+// the first certificate of a comma separated chain is used.
+func parseForwardedCertificate(value string) ([]byte, error) {
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		value = unescaped
+	}
+	if i := strings.IndexByte(value, ','); i >= 0 {
+		value = value[:i]
+	}
+
+	var der []byte
+	if block, _ := pem.Decode([]byte(value)); block != nil {
+		der = block.Bytes
+	} else {
+		var err error
+		der, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value), ""))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode forwarded client certificate: %w", err)
+		}
+	}
+
+	if _, err := x509.ParseCertificate(der); err != nil {
+		return nil, fmt.Errorf("failed to parse forwarded client certificate: %w", err)
+	}
+	return der, nil
+}
+
+// certificateThumbprint Returns the "x5t#S256" member of the "cnf" claim of
+// the token, and whether it is present
+func certificateThumbprint(tk jwt.Token) (string, bool) {
+	v, ok := tk.Get(confirmationKey)
+	if !ok {
+		return "", false
+	}
+	cnf, ok := v.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	x5t, ok := cnf[certificateThumbprintKey].(string)
+	return x5t, ok && len(x5t) > 0
+}
@@ -2,12 +2,15 @@ package traefik_jwt_middleware
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwa"
 	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwk"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jws"
 	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwt"
 )
 
@@ -16,6 +19,134 @@ type Config struct {
 	ProxyHeaderName string `json:"proxyHeaderName,omitempty"`
 	AuthHeader      string `json:"authHeader,omitempty"`
 	HeaderPrefix    string `json:"headerPrefix,omitempty"`
+	PublicKeyPEM    string `json:"publicKeyPEM,omitempty"`
+	// PublicKeyFile is the path of a JWKS or PEM file. It is reloaded when
+	// it changes, checked at most once per PublicKeyFileReloadInterval
+	// (default 5s). Invalid updates are ignored, keeping the last good keys,
+	// and counted as failed refreshes in the metrics.
+	PublicKeyFile               string `json:"publicKeyFile,omitempty"`
+	PublicKeyFileReloadInterval string `json:"publicKeyFileReloadInterval,omitempty"`
+	TryAllKeys                  bool   `json:"tryAllKeys,omitempty"`
+	// TypeHeader requires the "typ" header of tokens to be this media type,
+	// for example "at+jwt" to only accept access tokens (RFC 9068)
+	TypeHeader string `json:"typeHeader,omitempty"`
+	// Issuer and Audience, if set, must match the "iss" and "aud" claims
+	Issuer   string `json:"issuer,omitempty"`
+	Audience string `json:"audience,omitempty"`
+	// AccessTokenProfile only accepts JWT access tokens as defined in
+	// RFC 9068, issued by Issuer for Audience, which are both required. It
+	// cannot be combined with TypeHeader.
+	AccessTokenProfile bool `json:"accessTokenProfile,omitempty"`
+	// IDTokenProfile only accepts OpenID Connect ID tokens issued by Issuer
+	// for Audience, the client ID, which are both required. If IDTokenMaxAge
+	// is set, the end user must have authenticated within this duration.
+	IDTokenProfile bool   `json:"idTokenProfile,omitempty"`
+	IDTokenMaxAge  string `json:"idTokenMaxAge,omitempty"`
+	// RevocationFile is the path of a list of revoked tokens, see
+	// fileRevocation for its format. It is reloaded when it changes, checked
+	// at most once per RevocationFileReloadInterval (default 5s). Invalid
+	// updates are ignored like for PublicKeyFile.
+	RevocationFile               string `json:"revocationFile,omitempty"`
+	RevocationFileReloadInterval string `json:"revocationFileReloadInterval,omitempty"`
+	// Revocation can be set when embedding the middleware, to check tokens
+	// against a custom revocation list. It takes precedence over RevocationFile.
+	Revocation Revocation `json:"-"`
+	// ReplayProtection only accepts each token once. Used tokens are
+	// remembered until their expiry plus ReplaySkew (default 30s), up to
+	// ReplayCacheSize (default 10000) tokens. New tokens are refused with 503
+	// while the cache is full.
+	ReplayProtection bool   `json:"replayProtection,omitempty"`
+	ReplayCacheSize  int    `json:"replayCacheSize,omitempty"`
+	ReplaySkew       string `json:"replaySkew,omitempty"`
+	// DPoP verifies DPoP proofs of possession (RFC 9449) for tokens sent
+	// with the DPoP authorization scheme, which must be bound to the proof
+	// key. DPoP-bound tokens sent as Bearer tokens are rejected. If
+	// DPoPRequired is set, only DPoP-bound tokens are accepted. Proofs must
+	// be issued within DPoPProofMaxAge (default 1m), allowing for ReplaySkew,
+	// and are only accepted once. Their "htu" claim is compared with the
+	// X-Forwarded-Proto and X-Forwarded-Host headers only for requests from
+	// TrustedProxies.
+	DPoP            bool   `json:"dpop,omitempty"`
+	DPoPRequired    bool   `json:"dpopRequired,omitempty"`
+	DPoPProofMaxAge string `json:"dpopProofMaxAge,omitempty"`
+	// CertificateBound only accepts tokens bound to the client certificate
+	// of the mutual TLS connection (RFC 8705). If TLS is terminated by a
+	// proxy, the certificate is read from ClientCertHeader (default
+	// X-Forwarded-Tls-Client-Cert), only for requests from TrustedProxies
+	// (IP addresses or CIDRs). Forwarded headers are ignored for requests
+	// from other addresses.
+	CertificateBound bool     `json:"certificateBound,omitempty"`
+	ClientCertHeader string   `json:"clientCertHeader,omitempty"`
+	TrustedProxies   []string `json:"trustedProxies,omitempty"`
+	// TokenCacheSize enables caching up to this many verified tokens, to skip
+	// verifying their signature again when they are reused. Claims such as
+	// "exp" are still validated on every request.
+	TokenCacheSize int `json:"tokenCacheSize,omitempty"`
+	// JwksURL is the URL of a remote JWKS. The key set is fetched on first
+	// use and re-fetched when a token refers to an unknown key ID, at most
+	// once per JwksRefetchInterval (default 10s).
+	JwksURL             string `json:"jwksURL,omitempty"`
+	JwksRefetchInterval string `json:"jwksRefetchInterval,omitempty"`
+	// IntrospectionURL is the OAuth 2.0 token introspection endpoint (RFC
+	// 7662) used to resolve opaque tokens, i.e. tokens that are not a JWS.
+	// Requests are authenticated with IntrospectionClientID and
+	// IntrospectionClientSecret. Active tokens are cached, up to
+	// IntrospectionCacheSize (default 1000) tokens, until they expire or for
+	// at most IntrospectionCacheMaxAge (default 1m), and inactive tokens for
+	// 10s. The claims of introspected tokens are validated like JWT claims,
+	// including the token profiles. Requests are rejected with 503 if the
+	// endpoint cannot be reached or fails.
+	IntrospectionURL          string `json:"introspectionURL,omitempty"`
+	IntrospectionClientID     string `json:"introspectionClientID,omitempty"`
+	IntrospectionClientSecret string `json:"introspectionClientSecret,omitempty"`
+	IntrospectionCacheSize    int    `json:"introspectionCacheSize,omitempty"`
+	IntrospectionCacheMaxAge  string `json:"introspectionCacheMaxAge,omitempty"`
+	// InternalTokenKey is the PEM encoded private key of the gateway. If
+	// set, the token forwarded in ProxyHeaderName is not the caller's token,
+	// but an internal token signed with this key. It carries the
+	// InternalTokenClaims (default "sub") of the caller's token, and is
+	// issued by InternalTokenIssuer for InternalTokenAudience, valid for
+	// InternalTokenTTL (default 1m). InternalTokenKeyID defaults to the
+	// thumbprint of the key, and InternalTokenAlgorithm to the one matching
+	// the key type.
+	InternalTokenKey       string   `json:"internalTokenKey,omitempty"`
+	InternalTokenKeyID     string   `json:"internalTokenKeyID,omitempty"`
+	InternalTokenAlgorithm string   `json:"internalTokenAlgorithm,omitempty"`
+	InternalTokenIssuer    string   `json:"internalTokenIssuer,omitempty"`
+	InternalTokenAudience  string   `json:"internalTokenAudience,omitempty"`
+	InternalTokenTTL       string   `json:"internalTokenTTL,omitempty"`
+	InternalTokenClaims    []string `json:"internalTokenClaims,omitempty"`
+	// InternalJwksPath is the path on which the public JWKS of the gateway
+	// key is served, if set
+	InternalJwksPath string `json:"internalJwksPath,omitempty"`
+	// Bypass lists rules for requests that are forwarded without
+	// authentication, such as health checks or public routes. If
+	// BypassPreflight is set, CORS preflight requests are forwarded too.
+	Bypass          []BypassRule `json:"bypass,omitempty"`
+	BypassPreflight bool         `json:"bypassPreflight,omitempty"`
+	// OptionalAuth forwards requests without a token, or without a body
+	// signature in "bodySignature" mode, anonymously. Requests with an
+	// invalid token or signature are still rejected.
+	OptionalAuth bool `json:"optionalAuth,omitempty"`
+	// MetricsPath is the path on which the metrics of the middleware are
+	// served in the Prometheus text format, without authentication. Metrics
+	// are not served if empty.
+	MetricsPath string `json:"metricsPath,omitempty"`
+	// Mode is either "jwt" (default), to verify a JWT carried in AuthHeader,
+	// or "bodySignature", to verify a detached JWS carried in SignatureHeader
+	// (default "X-JWS-Signature") over the raw request body. The body is
+	// buffered up to MaxBodySize bytes (default 1MiB) for verification.
+	//
+	// Mode "report" verifies JWTs like "jwt", but forwards every request:
+	// the reasons requests would have been denied for are added to the
+	// X-Auth-Would-Deny header and counted in the metrics instead.
+	Mode            string `json:"mode,omitempty"`
+	SignatureHeader string `json:"signatureHeader,omitempty"`
+	MaxBodySize     int64  `json:"maxBodySize,omitempty"`
+	// ReportOnly lists deny reasons (as used in the metrics, e.g. "expired"
+	// or "revoked") that are only reported like in "report" mode, while
+	// requests denied for other reasons are still rejected
+	ReportOnly []string `json:"reportOnly,omitempty"`
 }
 
 func CreateConfig() *Config {
@@ -23,7 +154,33 @@ func CreateConfig() *Config {
 }
 
 func New(ctx context.Context, next http.Handler, config *Config, name string) (http.Handler, error) {
+	j, err := NewHandler(next, config, name)
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// NewHandler Returns the middleware as a plain net/http handler verifying
+// requests before forwarding them to next, for use outside of Traefik.
+// Handlers wrapping other handlers with the same configuration, caches and
+// metrics are returned by Wrap, in which case next may be nil.
+func NewHandler(next http.Handler, config *Config, name string) (*JWT, error) {
+	return newHandler(next, config, name, nil)
+}
+
+// Reload Returns the middleware for a new configuration, forwarding to next,
+// that keeps the state of j which must survive configuration changes: tokens
+// and DPoP proofs used before are still rejected as replays, and the metrics
+// keep counting. Other caches are rebuilt, since their entries depend on the
+// previous configuration.
+func (j *JWT) Reload(next http.Handler, config *Config) (*JWT, error) {
+	return newHandler(next, config, j.name, j)
+}
 
+// newHandler Creates the middleware, taking over the replay caches and
+// metrics of prev if it is not nil
+func newHandler(next http.Handler, config *Config, name string, prev *JWT) (*JWT, error) {
 	if len(config.Secret) == 0 {
 		config.Secret = "SECRET"
 	}
@@ -36,78 +193,601 @@ func New(ctx context.Context, next http.Handler, config *Config, name string) (h
 	if len(config.HeaderPrefix) == 0 {
 		config.HeaderPrefix = "Bearer"
 	}
+	if len(config.Mode) == 0 {
+		config.Mode = modeJWT
+	}
+	if config.Mode != modeJWT && config.Mode != modeBodySignature && config.Mode != modeReport {
+		return nil, fmt.Errorf("unknown mode %#v", config.Mode)
+	}
+	if config.AccessTokenProfile && config.IDTokenProfile {
+		return nil, errors.New("access token and ID token profiles are mutually exclusive")
+	}
+	if (config.AccessTokenProfile || config.IDTokenProfile) && (len(config.Issuer) == 0 || len(config.Audience) == 0) {
+		return nil, errors.New("token profiles require both an issuer and an audience")
+	}
+	if len(config.IntrospectionURL) > 0 && len(config.TypeHeader) > 0 {
+		return nil, errors.New("type header cannot be required with token introspection, since opaque tokens have none")
+	}
+	if config.AccessTokenProfile && len(config.TypeHeader) > 0 {
+		return nil, errors.New("type header cannot be combined with the access token profile, which requires the at+jwt type")
+	}
+	if len(config.SignatureHeader) == 0 {
+		config.SignatureHeader = defaultSignatureHeader
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = defaultMaxBodySize
+	}
+
+	options, err := parseOptions(config)
+	if err != nil {
+		return nil, err
+	}
+
+	bypassRules, err := compileBypassRules(config.Bypass)
+	if err != nil {
+		return nil, err
+	}
+	reportOnly, err := compileReportOnly(config.ReportOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	m := newMetrics(name)
+	if prev != nil {
+		m = prev.metrics
+	}
+
+	keys, err := newKeySource(config, m)
+	if err != nil {
+		return nil, err
+	}
+
+	revocation, err := newRevocation(config, m)
+	if err != nil {
+		return nil, err
+	}
+
+	var replay *replayCache
+	if config.ReplayProtection {
+		size := config.ReplayCacheSize
+		if size <= 0 {
+			size = defaultReplayCacheSize
+		}
+		skew, err := parseInterval(config.ReplaySkew, defaultReplaySkew)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse replay skew: %w", err)
+		}
+		if prev != nil && prev.replay != nil {
+			replay = prev.replay
+			replay.configure(size, skew)
+		} else {
+			replay = newReplayCache(size, skew)
+		}
+	}
+
+	trusted, err := parseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	var dpop *dpopVerifier
+	if config.DPoP || config.DPoPRequired {
+		maxAge, err := parseInterval(config.DPoPProofMaxAge, defaultDPoPProofMaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DPoP proof max age: %w", err)
+		}
+		skew, err := parseInterval(config.ReplaySkew, defaultReplaySkew)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse replay skew: %w", err)
+		}
+		size := config.ReplayCacheSize
+		if size <= 0 {
+			size = defaultReplayCacheSize
+		}
+		dpop = newDPoPVerifier(config.DPoPRequired, maxAge, skew, size, trusted)
+		if prev != nil && prev.dpop != nil {
+			dpop.replay = prev.dpop.replay
+			dpop.replay.configure(size, skew)
+		}
+	}
+
+	var binding *certificateBinding
+	if config.CertificateBound {
+		header := config.ClientCertHeader
+		if len(header) == 0 {
+			header = defaultClientCertHeader
+		}
+		binding = newCertificateBinding(header, trusted)
+	}
+
+	var introspection *introspector
+	if len(config.IntrospectionURL) > 0 {
+		size := config.IntrospectionCacheSize
+		if size <= 0 {
+			size = defaultIntrospectionCacheSize
+		}
+		maxAge, err := parseInterval(config.IntrospectionCacheMaxAge, defaultIntrospectionCacheMaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse introspection cache max age: %w", err)
+		}
+		introspection = newIntrospector(config.IntrospectionURL, config.IntrospectionClientID, config.IntrospectionClientSecret, size, maxAge)
+	}
+
+	var reissue *reissuer
+	if len(config.InternalTokenKey) > 0 {
+		ttl, err := parseInterval(config.InternalTokenTTL, defaultInternalTokenTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse internal token TTL: %w", err)
+		}
+		reissue, err = newReissuer(config.InternalTokenKey, config.InternalTokenKeyID, jwa.SignatureAlgorithm(config.InternalTokenAlgorithm),
+			config.InternalTokenIssuer, config.InternalTokenAudience, ttl, config.InternalTokenClaims)
+		if err != nil {
+			return nil, err
+		}
+	} else if len(config.InternalJwksPath) > 0 {
+		return nil, errors.New("internal JWKS path requires an internal token key")
+	}
+
+	var idTokenMaxAge time.Duration
+	if config.IDTokenProfile {
+		idTokenMaxAge, err = parseInterval(config.IDTokenMaxAge, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ID token max age: %w", err)
+		}
+	}
+
+	var cache *tokenCache
+	if config.TokenCacheSize > 0 {
+		cache = newTokenCache(config.TokenCacheSize, 0)
+	}
+	m.setCache(cache)
 
 	return &JWT{
 		next:            next,
 		name:            name,
 		secret:          config.Secret,
+		keys:            keys,
+		parseOptions:    options,
+		validateOptions: validateOptions(config),
+		revocation:      revocation,
+		replay:          replay,
+		dpop:            dpop,
+		binding:         binding,
+		introspection:   introspection,
+		reissue:         reissue,
+		jwksPath:        config.InternalJwksPath,
+		bypassRules:     bypassRules,
+		bypassPreflight: config.BypassPreflight,
+		optionalAuth:    config.OptionalAuth,
+		reportOnly:      reportOnly,
+		cache:           cache,
+		idTokenMaxAge:   idTokenMaxAge,
+		metrics:         m,
+		metricsPath:     config.MetricsPath,
 		proxyHeaderName: config.ProxyHeaderName,
 		authHeader:      config.AuthHeader,
 		headerPrefix:    config.HeaderPrefix,
+		mode:            config.Mode,
+		signatureHeader: config.SignatureHeader,
+		maxBodySize:     config.MaxBodySize,
 	}, nil
 }
 
+// Wrap Returns a handler verifying requests before forwarding them to next.
+// It shares the configuration, caches and metrics of the middleware, so
+// that it can be used as a net/http middleware constructor:
+//
+//	mux.Handle("/api/", j.Wrap(api))
+func (j *JWT) Wrap(next http.Handler) http.Handler {
+	wrapped := *j
+	wrapped.next = next
+	return &wrapped
+}
+
 type JWT struct {
 	next            http.Handler
 	name            string
 	secret          string
+	keys            keySource
+	parseOptions    []jwt.Option
+	validateOptions []jwt.ValidateOption
+	revocation      Revocation
+	replay          *replayCache
+	dpop            *dpopVerifier
+	binding         *certificateBinding
+	introspection   *introspector
+	reissue         *reissuer
+	jwksPath        string
+	bypassRules     []bypassRule
+	bypassPreflight bool
+	optionalAuth    bool
+	reportOnly      map[string]bool
+	cache           *tokenCache
+	idTokenMaxAge   time.Duration
+	metrics         *metrics
+	metricsPath     string
 	proxyHeaderName string
 	authHeader      string
 	headerPrefix    string
+	mode            string
+	signatureHeader string
+	maxBodySize     int64
 }
 
 func (j *JWT) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if len(j.metricsPath) > 0 && req.URL.Path == j.metricsPath {
+		j.metrics.ServeHTTP(res, req)
+		return
+	}
+	if len(j.jwksPath) > 0 && req.URL.Path == j.jwksPath {
+		j.reissue.ServeHTTP(res, req)
+		return
+	}
+
+	// only the middleware reports denials to upstream services
+	req.Header.Del(wouldDenyHeader)
+
+	if j.bypass(req) {
+		j.forwardAnonymous(res, req)
+		return
+	}
+
+	if j.mode == modeBodySignature {
+		j.serveBodySignature(res, req)
+		return
+	}
+
 	headerToken := req.Header.Get(j.authHeader)
 
 	if len(headerToken) == 0 {
-		http.Error(res, "Request error", http.StatusBadRequest)
+		if j.optionalAuth || !j.deny(res, req, reasonMissing, http.StatusBadRequest, "") {
+			j.forwardAnonymous(res, req)
+		}
 		return
 	}
 
+	var scheme string
+	if j.dpop != nil {
+		scheme, headerToken = splitDPoPScheme(headerToken)
+	}
+
 	token, preprocessError := preprocessJWT(headerToken, j.headerPrefix)
 	if preprocessError != nil {
-		http.Error(res, "Request error", http.StatusBadRequest)
+		if !j.deny(res, req, reasonMalformed, http.StatusBadRequest, "") {
+			j.forwardAnonymous(res, req)
+		}
 		return
 	}
 
-	tk, verificationError := verifyJWT(token, j.secret)
+	start := time.Now()
+	tk, verificationError := j.verify(req.Context(), token)
+	j.metrics.ObserveVerification(time.Since(start))
 	if verificationError != nil {
-		http.Error(res, "Not allowed", http.StatusUnauthorized)
+		reason := denyReason(verificationError, token)
+		if !j.deny(res, req, reason, denyStatus(reason), "") {
+			j.forwardAnonymous(res, req)
+		}
 		return
 	}
 
-	if tk != nil {
-		// Inject header as proxypayload or configured name
-		req.Header.Add(j.proxyHeaderName, token)
-		fmt.Println(req.Header)
-		j.next.ServeHTTP(res, req)
+	if j.dpop != nil {
+		if err := j.dpop.check(req, scheme, token, tk.Token); err != nil &&
+			j.deny(res, req, reasonInvalidProof, http.StatusUnauthorized, dpopChallenge(err)) {
+			return
+		}
+	}
+
+	if j.binding != nil {
+		if err := j.binding.check(req, tk.Token); err != nil &&
+			j.deny(res, req, reasonInvalidProof, http.StatusUnauthorized, `Bearer error="invalid_token", error_description="certificate binding"`) {
+			return
+		}
+	}
+
+	if j.revocation != nil {
+		revoked, err := j.revocation.IsRevoked(req.Context(), tk.Token)
+		if err != nil {
+			if j.deny(res, req, reasonError, http.StatusInternalServerError, "") {
+				return
+			}
+		} else if revoked && j.deny(res, req, reasonRevoked, http.StatusUnauthorized, `Bearer error="invalid_token", error_description="revoked"`) {
+			return
+		}
+	}
+
+	if j.replay != nil {
+		if err := j.replay.Use(tk.Token, token); err != nil {
+			var replayErr *ReplayError
+			if errors.As(err, &replayErr) {
+				if j.deny(res, req, reasonReplayed, http.StatusUnauthorized, `Bearer error="invalid_token", error_description="replayed"`) {
+					return
+				}
+			} else if j.deny(res, req, reasonError, http.StatusServiceUnavailable, "") {
+				return
+			}
+		}
+	}
+
+	if j.reissue != nil {
+		internal, err := j.reissue.Issue(tk.Token)
+		if err != nil {
+			if !j.deny(res, req, reasonError, http.StatusInternalServerError, "") {
+				j.forwardAnonymous(res, req)
+			}
+			return
+		}
+		// forward the internal token instead
+		token = internal
+	}
+
+	// Inject header as proxypayload or configured name, replacing any value
+	// set by the caller
+	req.Header.Set(j.proxyHeaderName, token)
+	j.forward(res, req.WithContext(NewContext(req.Context(), tk)))
+}
+
+// verify Verifies the token signature and validates its claims against the
+// current time. Only the signature verification is cached, if enabled.
+// Opaque tokens are introspected instead, if enabled.
+func (j *JWT) verify(ctx context.Context, token string) (*VerifiedToken, error) {
+	var tk *VerifiedToken
+	var err error
+	if j.introspection != nil && isOpaqueToken(token) {
+		tk, err = j.introspection.Introspect(ctx, token)
+		if err == nil {
+			err = jwt.ValidateProfiles(tk.Token, j.parseOptions...)
+		}
 	} else {
-		http.Error(res, "Not allowed", http.StatusUnauthorized)
+		tk, err = j.verifyCached(ctx, token)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := jwt.Validate(tk.Token, j.validateOptions...); err != nil {
+		return nil, err
+	}
+	return tk, nil
+}
+
+// verifyCached Verifies the token signature, unless the token is in the
+// cache of verified tokens
+func (j *JWT) verifyCached(ctx context.Context, token string) (*VerifiedToken, error) {
+	var keySet *jwk.Set
+	if j.keys != nil {
+		var err error
+		keySet, err = j.keys.KeySet(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var tk *VerifiedToken
+	var ok bool
+	if j.cache != nil {
+		tk, ok = j.cache.Get(token, keySet)
+	}
+	if !ok {
+		var err error
+		tk, keySet, err = j.verifySignature(ctx, token, keySet)
+		if err != nil {
+			return nil, err
+		}
+		if j.cache != nil {
+			// the ID token max age is only checked when verifying, so
+			// cached ID tokens must not outlive it
+			var until time.Time
+			if j.idTokenMaxAge > 0 {
+				until = jwt.IDToken{Token: tk.Token}.AuthTime().Add(j.idTokenMaxAge)
+			}
+			j.cache.Add(token, keySet, tk, until)
+		}
+	}
+	return tk, nil
+}
+
+// verifySignature Verifies the token with the given key set, or with the
+// secret if there is none. If the token refers to a key ID that is not in the
+// key set, the key set is re-fetched (if supported) and verification retried
+// once. It returns the key set the token was verified with.
+func (j *JWT) verifySignature(ctx context.Context, token string, keySet *jwk.Set) (*VerifiedToken, *jwk.Set, error) {
+	if keySet == nil {
+		tk, err := verifyJWT(token, j.secret, j.parseOptions...)
+		return tk, nil, err
+	}
+
+	tk, err := verifyJWTWithKeySet(token, keySet, j.parseOptions...)
+	var unknownKeyID *jwt.UnknownKeyIDError
+	if err == nil || !errors.As(err, &unknownKeyID) {
+		return tk, keySet, err
+	}
+
+	r, ok := j.keys.(refresher)
+	if !ok {
+		return nil, nil, err
+	}
+	keySet, refreshErr := r.Refresh(ctx)
+	if refreshErr != nil {
+		return nil, nil, err
 	}
+	tk, err = verifyJWTWithKeySet(token, keySet, j.parseOptions...)
+	return tk, keySet, err
+}
+
+// MetricsHandler Returns a handler serving the metrics of the middleware in
+// the Prometheus text exposition format
+func (j *JWT) MetricsHandler() http.Handler {
+	return j.metrics
+}
+
+// InternalJwksHandler Returns a handler serving the public JWKS of the key
+// internal tokens are signed with, or nil if tokens are not re-issued
+func (j *JWT) InternalJwksHandler() http.Handler {
+	if j.reissue == nil {
+		return nil
+	}
+	return j.reissue
+}
+
+// TokenCacheStats Returns the usage of the verified token cache. All values
+// are zero if the cache is disabled.
+func (j *JWT) TokenCacheStats() TokenCacheStats {
+	if j.cache == nil {
+		return TokenCacheStats{}
+	}
+	return j.cache.Stats()
 }
 
 // verifyJWT Verifies jwt token with jwks
-func verifyJWT(token string, jwks string) (*jwt.Token, error) {
+func verifyJWT(token string, jwks string, options ...jwt.Option) (*VerifiedToken, error) {
 	jwkSet, err := jwk.ParseString(jwks)
 	if err != nil {
 		return nil, err
 	}
 
-	tk, err := jwt.ParseString(token, jwt.WithKeySet(jwkSet), jwt.UseDefaultKey(true), jwt.WithClock(jwt.ClockFunc(time.Now)))
+	return verifyJWTWithKeySet(token, jwkSet, options...)
+}
+
+// verifyJWTWithKeySet Verifies jwt token with an already parsed key set,
+// returning it along with the key that verified it and its headers
+func verifyJWTWithKeySet(token string, jwkSet *jwk.Set, options ...jwt.Option) (*VerifiedToken, error) {
+	var key jwk.Key
+	options = append([]jwt.Option{jwt.WithKeySet(jwkSet), jwt.UseDefaultKey(true), jwt.WithClock(jwt.ClockFunc(time.Now)), jwt.WithKeyUsed(&key)}, options...)
+	tk, err := jwt.ParseString(token, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := jws.ParseString(token)
 	if err != nil {
 		return nil, err
 	}
 
-	return &tk, nil
+	return &VerifiedToken{Token: tk, Key: key, Headers: msg.Signatures()[0].ProtectedHeaders()}, nil
+}
+
+// parseOptions Returns the options to parse tokens with, as described by
+// the config
+func parseOptions(config *Config) ([]jwt.Option, error) {
+	options := []jwt.Option{jwt.TryAllKeys(config.TryAllKeys)}
+	if len(config.TypeHeader) > 0 {
+		options = append(options, jwt.WithTypeHeader(config.TypeHeader))
+	}
+	if config.AccessTokenProfile {
+		options = append(options, jwt.WithAccessTokenProfile(config.Issuer, config.Audience))
+	}
+	if config.IDTokenProfile {
+		var idTokenOptions []jwt.IDTokenOption
+		if len(config.IDTokenMaxAge) > 0 {
+			maxAge, err := time.ParseDuration(config.IDTokenMaxAge)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse ID token max age: %w", err)
+			}
+			idTokenOptions = append(idTokenOptions, jwt.WithMaxAge(maxAge))
+		}
+		options = append(options, jwt.WithIDTokenProfile(config.Issuer, config.Audience, idTokenOptions...))
+	}
+	return options, nil
+}
+
+// validateOptions Returns the options to validate the claims of tokens
+// with, as described by the config
+func validateOptions(config *Config) []jwt.ValidateOption {
+	options := []jwt.ValidateOption{jwt.WithClock(jwt.ClockFunc(time.Now))}
+	if len(config.Issuer) > 0 {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if len(config.Audience) > 0 {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+	return options
+}
+
+// newKeySource Creates the key source described by the config: a remote JWKS,
+// a key file, or PEM encoded public key(s). It returns nil if none is
+// configured, and an error if more than one is.
+func newKeySource(config *Config, m *metrics) (keySource, error) {
+	var sources []string
+	for _, source := range []struct{ name, value string }{
+		{"jwksURL", config.JwksURL},
+		{"publicKeyFile", config.PublicKeyFile},
+		{"publicKeyPEM", config.PublicKeyPEM},
+	} {
+		if len(source.value) > 0 {
+			sources = append(sources, source.name)
+		}
+	}
+	if len(sources) > 1 {
+		return nil, fmt.Errorf("only one key source can be configured, got %s", strings.Join(sources, ", "))
+	}
+
+	if len(config.JwksURL) > 0 {
+		interval, err := parseInterval(config.JwksRefetchInterval, defaultJwksRefetchInterval)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWKS re-fetch interval: %w", err)
+		}
+		return newRemoteKeySet(config.JwksURL, interval, m), nil
+	}
+
+	if len(config.PublicKeyFile) > 0 {
+		interval, err := parseInterval(config.PublicKeyFileReloadInterval, defaultKeyFileReloadInterval)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key file reload interval: %w", err)
+		}
+		return newFileKeySet(config.PublicKeyFile, interval, m)
+	}
+
+	keySet, err := loadPEMKeySet(config)
+	if err != nil || keySet == nil {
+		return nil, err
+	}
+	return &staticKeySet{set: keySet}, nil
+}
+
+// newRevocation Creates the revocation list described by the config. It
+// returns nil if none is configured.
+func newRevocation(config *Config, m *metrics) (Revocation, error) {
+	if config.Revocation != nil {
+		return config.Revocation, nil
+	}
+	if len(config.RevocationFile) == 0 {
+		return nil, nil
+	}
+
+	interval, err := parseInterval(config.RevocationFileReloadInterval, defaultRevocationFileReloadInterval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse revocation file reload interval: %w", err)
+	}
+	return newFileRevocation(config.RevocationFile, interval, m)
+}
+
+// parseInterval Parses a duration from the config, falling back to the given
+// default if it is not set
+func parseInterval(value string, fallback time.Duration) (time.Duration, error) {
+	if len(value) == 0 {
+		return fallback, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// loadPEMKeySet Parses the PEM encoded public key(s) given inline. It returns
+// a nil set if none are configured.
+func loadPEMKeySet(config *Config) (*jwk.Set, error) {
+	if len(config.PublicKeyPEM) == 0 {
+		return nil, nil
+	}
+
+	keySet, err := jwk.ParsePEM([]byte(config.PublicKeyPEM))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key PEM: %w", err)
+	}
+	return keySet, nil
 }
 
 // preprocessJWT Takes the request header string, strips prefix and whitespaces and returns a Token
 func preprocessJWT(reqHeader string, prefix string) (string, error) {
-	// fmt.Println("==> [processHeader] SplitAfter")
-	// structuredHeader := strings.SplitAfter(reqHeader, "Bearer ")[1]
 	cleanedString := strings.TrimPrefix(reqHeader, prefix)
 	cleanedString = strings.TrimSpace(cleanedString)
-	// fmt.Println("<== [processHeader] SplitAfter", cleanedString)
 
 	return cleanedString, nil
 }
@@ -0,0 +1,172 @@
+package traefik_jwt_middleware
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwa"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwk"
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwt"
+)
+
+const defaultInternalTokenTTL = time.Minute
+
+// defaultInternalTokenClaims are the claims copied from the verified token
+// when none are configured
+var defaultInternalTokenClaims = []string{jwt.SubjectKey}
+
+// reissuer mints short-lived internal tokens for upstream services, signed
+// with the gateway key, from the claims of verified tokens. Upstream
+// services then only need to trust the gateway key, whose public JWKS can be
+// served by the middleware.
+type reissuer struct {
+	key      jwk.Key
+	alg      jwa.SignatureAlgorithm
+	issuer   string
+	audience string
+	ttl      time.Duration
+	claims   []string
+	jwks     []byte
+}
+
+// newReissuer Parses the PEM encoded private key of the gateway. The key ID
+// defaults to the RFC 7638 thumbprint of the key, and the algorithm to the
+// one matching the key type.
+func newReissuer(privateKeyPEM, keyID string, alg jwa.SignatureAlgorithm, issuer, audience string, ttl time.Duration, claims []string) (*reissuer, error) {
+	if len(issuer) == 0 || len(audience) == 0 {
+		return nil, errors.New("internal token issuer and audience are required")
+	}
+
+	key, err := jwk.ParseKeyPEM([]byte(privateKeyPEM))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse internal token key: %w", err)
+	}
+	switch key.(type) {
+	case jwk.RSAPrivateKey, jwk.ECDSAPrivateKey, jwk.OKPPrivateKey:
+	default:
+		return nil, errors.New("internal token key must be a private key")
+	}
+
+	if len(alg) == 0 {
+		alg, err = defaultAlgorithm(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(keyID) == 0 {
+		thumbprint, err := key.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute internal token key ID: %w", err)
+		}
+		keyID = base64.RawURLEncoding.EncodeToString(thumbprint)
+	}
+	for name, value := range map[string]interface{}{
+		jwk.KeyIDKey:     keyID,
+		jwk.AlgorithmKey: alg,
+		jwk.KeyUsageKey:  jwk.ForSignature,
+	} {
+		if err := key.Set(name, value); err != nil {
+			return nil, fmt.Errorf("failed to set internal token key %s: %w", name, err)
+		}
+	}
+
+	public, err := jwk.NewSet(key).PublicSet()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get internal token public key: %w", err)
+	}
+	jwks, err := json.Marshal(public)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode internal token JWKS: %w", err)
+	}
+
+	if len(claims) == 0 {
+		claims = defaultInternalTokenClaims
+	}
+	r := &reissuer{
+		key:      key,
+		alg:      alg,
+		issuer:   issuer,
+		audience: audience,
+		ttl:      ttl,
+		claims:   claims,
+		jwks:     jwks,
+	}
+
+	// fail early if the algorithm cannot be used with the key
+	if _, err := r.Issue(jwt.New()); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Issue Mints an internal token carrying the configured claims of the given
+// verified token
+func (r *reissuer) Issue(tk jwt.Token) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", fmt.Errorf("failed to generate JWT ID: %w", err)
+	}
+
+	internal := jwt.New()
+	for _, name := range r.claims {
+		if v, ok := tk.Get(name); ok {
+			if err := internal.Set(name, v); err != nil {
+				return "", fmt.Errorf("failed to copy %s claim: %w", name, err)
+			}
+		}
+	}
+
+	now := time.Now()
+	for name, value := range map[string]interface{}{
+		jwt.IssuerKey:     r.issuer,
+		jwt.AudienceKey:   r.audience,
+		jwt.IssuedAtKey:   now,
+		jwt.ExpirationKey: now.Add(r.ttl),
+		jwt.JwtIDKey:      hex.EncodeToString(jti),
+	} {
+		if err := internal.Set(name, value); err != nil {
+			return "", fmt.Errorf("failed to set %s claim: %w", name, err)
+		}
+	}
+
+	signed, err := jwt.Sign(internal, r.alg, r.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign internal token: %w", err)
+	}
+	return string(signed), nil
+}
+
+// ServeHTTP Serves the public JWKS of the gateway key
+func (r *reissuer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/jwk-set+json")
+	_, _ = res.Write(r.jwks)
+}
+
+// defaultAlgorithm Returns the signature algorithm matching the key type
+func defaultAlgorithm(key jwk.Key) (jwa.SignatureAlgorithm, error) {
+	switch key := key.(type) {
+	case jwk.RSAPrivateKey:
+		return jwa.RS256, nil
+	case jwk.ECDSAPrivateKey:
+		switch key.Crv() {
+		case jwa.P256:
+			return jwa.ES256, nil
+		case jwa.P384:
+			return jwa.ES384, nil
+		case jwa.P521:
+			return jwa.ES512, nil
+		}
+	case jwk.OKPPrivateKey:
+		if key.Crv() == jwa.Ed25519 {
+			return jwa.EdDSA, nil
+		}
+	}
+	return "", errors.New("unsupported internal token key type")
+}
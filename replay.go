@@ -0,0 +1,178 @@
+package traefik_jwt_middleware
+
+import (
+	"container/heap"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwt"
+)
+
+const (
+	defaultReplayCacheSize = 10000
+	defaultReplaySkew      = 30 * time.Second
+	// defaultReplayTTL is how long tokens without an "exp" claim are remembered
+	defaultReplayTTL = 24 * time.Hour
+)
+
+var (
+	// errAlreadyRecorded is returned when the key was already remembered
+	errAlreadyRecorded = errors.New("already recorded")
+	// errReplayCacheFull is returned when the cache holds as many unexpired
+	// entries as it can, so that new tokens cannot be checked for replay
+	errReplayCacheFull = errors.New("replay cache is full")
+)
+
+// ReplayError is returned when a token that was already used is presented
+// again while replay protection is enabled
+type ReplayError struct {
+	jti string
+}
+
+// JwtID returns the JWT ID of the replayed token, or an empty string if
+// the token was identified by its signature
+func (e *ReplayError) JwtID() string {
+	return e.jti
+}
+
+func (e *ReplayError) Error() string {
+	if len(e.jti) == 0 {
+		return "token has already been used"
+	}
+	return "token with JWT ID " + e.jti + " has already been used"
+}
+
+// replayCache remembers the tokens it has seen until they expire, so that
+// each token is only accepted once. Tokens are identified by their issuer
+// and JWT ID, or by a hash of their signature if they have no JWT ID.
+//
+// The cache holds at most size entries. Unexpired entries are never evicted,
+// since forgetting them would let their tokens be replayed: when full, new
+// tokens are refused until entries expire.
+type replayCache struct {
+	size int
+	skew time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*replayEntry
+	expiry  replayHeap
+}
+
+type replayEntry struct {
+	key   string
+	until time.Time
+}
+
+func newReplayCache(size int, skew time.Duration) *replayCache {
+	return &replayCache{
+		size:    size,
+		skew:    skew,
+		entries: make(map[string]*replayEntry),
+	}
+}
+
+// Use records the given token as used. It returns a *ReplayError if the
+// token was already used before, or errReplayCacheFull if it cannot be
+// recorded.
+func (c *replayCache) Use(token jwt.Token, compact string) error {
+	key := replayKey(token, compact)
+
+	until := time.Now().Add(defaultReplayTTL)
+	if exp := token.Expiration(); !exp.IsZero() {
+		until = exp
+	}
+
+	c.mu.Lock()
+	err := c.recordLocked(key, until.Add(c.skew))
+	c.mu.Unlock()
+	if err != nil {
+		if err == errAlreadyRecorded {
+			return &ReplayError{jti: token.JwtID()}
+		}
+		return err
+	}
+	return nil
+}
+
+// record remembers the given key until the given time. It returns
+// errAlreadyRecorded if the key was already remembered, and
+// errReplayCacheFull if there is no room left for it.
+func (c *replayCache) record(key string, until time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.recordLocked(key, until)
+}
+
+// recordLocked is record, with c.mu held by the caller
+func (c *replayCache) recordLocked(key string, until time.Time) error {
+	now := time.Now()
+	for len(c.expiry) > 0 && !c.expiry[0].until.After(now) {
+		delete(c.entries, heap.Pop(&c.expiry).(*replayEntry).key)
+	}
+
+	if _, ok := c.entries[key]; ok {
+		return errAlreadyRecorded
+	}
+	if len(c.expiry) >= c.size {
+		return errReplayCacheFull
+	}
+
+	entry := &replayEntry{key: key, until: until}
+	heap.Push(&c.expiry, entry)
+	c.entries[key] = entry
+	return nil
+}
+
+// configure Changes the size and skew of the cache, keeping the tokens it
+// remembers. If it holds more entries than the new size, new tokens are
+// refused until enough of them expire.
+func (c *replayCache) configure(size int, skew time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size = size
+	c.skew = skew
+}
+
+// Len returns the number of remembered tokens
+func (c *replayCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+func replayKey(token jwt.Token, compact string) string {
+	if jti := token.JwtID(); len(jti) > 0 {
+		return "jti:" + token.Issuer() + "\x00" + jti
+	}
+
+	sig := compact
+	if i := strings.LastIndexByte(compact, '.'); i >= 0 {
+		sig = compact[i+1:]
+	}
+	sum := sha256.Sum256([]byte(sig))
+	return "sig:" + hex.EncodeToString(sum[:])
+}
+
+// replayHeap orders entries by expiry, implementing heap.Interface
+type replayHeap []*replayEntry
+
+func (h replayHeap) Len() int           { return len(h) }
+func (h replayHeap) Less(i, j int) bool { return h[i].until.Before(h[j].until) }
+func (h replayHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *replayHeap) Push(x interface{}) {
+	*h = append(*h, x.(*replayEntry))
+}
+
+func (h *replayHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return entry
+}
@@ -0,0 +1,95 @@
+package traefik_jwt_middleware
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// modeReport verifies tokens like modeJWT, but never rejects requests: the
+// reasons they would have been rejected for are reported instead
+const modeReport = "report"
+
+// wouldDenyHeader is added to forwarded requests with each reason the
+// request would have been rejected for, if the reason is only reported
+const wouldDenyHeader = "X-Auth-Would-Deny"
+
+// compileReportOnly Returns the set of deny reasons that are only reported
+func compileReportOnly(reasons []string) (map[string]bool, error) {
+	known := make(map[string]bool, len(denyReasons))
+	for _, reason := range denyReasons {
+		known[reason] = true
+	}
+
+	reportOnly := make(map[string]bool, len(reasons))
+	for _, reason := range reasons {
+		if !known[reason] {
+			return nil, fmt.Errorf("unknown report-only reason %#v", reason)
+		}
+		reportOnly[reason] = true
+	}
+	return reportOnly, nil
+}
+
+// isReportOnly Reports whether requests denied for the given reason must be
+// forwarded anyway
+func (j *JWT) isReportOnly(reason string) bool {
+	return j.mode == modeReport || j.reportOnly[reason]
+}
+
+// deny Rejects the request for the given reason with the given status, and
+// the given WWW-Authenticate challenge if any. If the reason is only
+// reported, the request is not rejected, but marked with the reason in the
+// X-Auth-Would-Deny header. It returns whether the request was rejected.
+func (j *JWT) deny(res http.ResponseWriter, req *http.Request, reason string, status int, challenge string) bool {
+	if j.isReportOnly(reason) {
+		j.metrics.Report(reason)
+		req.Header.Add(wouldDenyHeader, reason)
+		return false
+	}
+
+	j.metrics.Deny(reason)
+	if len(challenge) > 0 {
+		res.Header().Set("WWW-Authenticate", challenge)
+	}
+	http.Error(res, errorMessage(status), status)
+	return true
+}
+
+// forward Forwards the request to the next handler. It is only counted as
+// allowed if no denial was reported for it.
+func (j *JWT) forward(res http.ResponseWriter, req *http.Request) {
+	if len(req.Header.Values(wouldDenyHeader)) == 0 {
+		j.metrics.Allow()
+	}
+	j.next.ServeHTTP(res, req)
+}
+
+// denyStatus Returns the status of responses denying a request whose token
+// failed to verify for the given reason. Errors reaching the authorization
+// server are not the fault of the token, so they are reported as unavailable.
+func denyStatus(reason string) int {
+	switch reason {
+	case reasonForbidden:
+		return http.StatusForbidden
+	case reasonError:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusUnauthorized
+	}
+}
+
+// errorMessage Returns the body of responses rejected with the given status
+func errorMessage(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "Request error"
+	case http.StatusRequestEntityTooLarge:
+		return "Request entity too large"
+	case http.StatusInternalServerError:
+		return "Internal error"
+	case http.StatusServiceUnavailable:
+		return "Service unavailable"
+	default:
+		return "Not allowed"
+	}
+}
@@ -0,0 +1,231 @@
+package traefik_jwt_middleware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwt"
+)
+
+const (
+	defaultRevocationFileReloadInterval = 5 * time.Second
+	revocationSweepInterval             = time.Minute
+)
+
+// Revocation decides whether a token that was successfully verified has
+// been revoked before its expiry
+type Revocation interface {
+	IsRevoked(ctx context.Context, token jwt.Token) (bool, error)
+}
+
+// MemoryRevocation is an in-memory Revocation. Tokens are revoked either
+// individually by their JWT ID ("jti"), or for a subject ("sub") as a whole
+// when they were issued ("iat") before a given time.
+//
+// Revoked JWT IDs are evicted once the token they belong to expires, since
+// an expired token is rejected anyway.
+type MemoryRevocation struct {
+	mu        sync.RWMutex
+	ids       map[string]time.Time
+	subjects  map[string]time.Time
+	nextSweep time.Time
+}
+
+// NewMemoryRevocation creates an empty MemoryRevocation
+func NewMemoryRevocation() *MemoryRevocation {
+	return &MemoryRevocation{
+		ids:      make(map[string]time.Time),
+		subjects: make(map[string]time.Time),
+	}
+}
+
+// RevokeToken revokes the token with the given JWT ID. The entry is kept
+// until exp, the expiry of the token, or forever if exp is zero.
+func (m *MemoryRevocation) RevokeToken(jti string, exp time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if !exp.IsZero() && !exp.After(now) {
+		return
+	}
+	m.ids[jti] = exp
+
+	if now.After(m.nextSweep) {
+		m.sweep(now)
+	}
+}
+
+// RevokeSubject revokes all tokens of the given subject that were issued
+// before the given time, or all of them if issuedBefore is zero. Tokens
+// without an "iat" claim are revoked too.
+func (m *MemoryRevocation) RevokeSubject(sub string, issuedBefore time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subjects[sub] = issuedBefore
+}
+
+// IsRevoked implements Revocation
+func (m *MemoryRevocation) IsRevoked(ctx context.Context, token jwt.Token) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if jti := token.JwtID(); len(jti) > 0 {
+		if exp, ok := m.ids[jti]; ok && (exp.IsZero() || exp.After(time.Now())) {
+			return true, nil
+		}
+	}
+
+	if sub := token.Subject(); len(sub) > 0 {
+		if issuedBefore, ok := m.subjects[sub]; ok {
+			iat := token.IssuedAt()
+			if issuedBefore.IsZero() || iat.IsZero() || iat.Before(issuedBefore) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// Len returns the number of revoked JWT IDs and subjects
+func (m *MemoryRevocation) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.ids) + len(m.subjects)
+}
+
+func (m *MemoryRevocation) sweep(now time.Time) {
+	for jti, exp := range m.ids {
+		if !exp.IsZero() && !exp.After(now) {
+			delete(m.ids, jti)
+		}
+	}
+	m.nextSweep = now.Add(revocationSweepInterval)
+}
+
+// fileRevocation is a Revocation backed by a local file, which is reloaded
+// when it changes. Each line of the file is one of
+//
+//	jti <id> [<exp>]
+//	sub <subject> [<iat>]
+//
+// where <exp> and <iat> are unix timestamps. A "jti" line revokes a single
+// token, until its expiry if given. A "sub" line revokes all tokens of the
+// subject issued before <iat>, or all of them if omitted. Empty lines and
+// lines starting with # are ignored. Updates that fail to parse are ignored,
+// keeping the last good list, and counted as failed refreshes. Like for
+// fileKeySet, the file is read and parsed without holding the lock.
+type fileRevocation struct {
+	metrics *metrics
+
+	mu        sync.Mutex
+	file      watchedFile
+	revoked   *MemoryRevocation
+	reloading bool
+}
+
+// newFileRevocation reads the revocation list from the given file. Unlike
+// later reloads, failing to read the initial list is an error.
+func newFileRevocation(path string, interval time.Duration, m *metrics) (*fileRevocation, error) {
+	f := &fileRevocation{metrics: m, file: watchedFile{path: path, interval: interval}}
+	revoked, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	f.revoked = revoked
+	return f, nil
+}
+
+// IsRevoked implements Revocation
+func (f *fileRevocation) IsRevoked(ctx context.Context, token jwt.Token) (bool, error) {
+	return f.current().IsRevoked(ctx, token)
+}
+
+// current returns the current revocation list, reloading the file first if
+// it changed since it was last checked
+func (f *fileRevocation) current() *MemoryRevocation {
+	f.mu.Lock()
+	if f.reloading || !f.file.due() {
+		revoked := f.revoked
+		f.mu.Unlock()
+		return revoked
+	}
+	// the file is only accessed by the reloading caller until it is done
+	f.reloading = true
+	f.mu.Unlock()
+
+	revoked, err := f.load()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil && revoked != nil {
+		f.revoked = revoked
+	}
+	f.reloading = false
+	return f.revoked
+}
+
+// load reads and parses the file, returning nil if it did not change
+func (f *fileRevocation) load() (*MemoryRevocation, error) {
+	buf, err := f.file.read()
+	if buf == nil {
+		if err != nil {
+			f.metrics.Refreshed(sourceRevocationFile, err)
+		}
+		return nil, err
+	}
+
+	revoked, err := parseRevocationList(buf)
+	f.metrics.Refreshed(sourceRevocationFile, err)
+	if err != nil {
+		return nil, err
+	}
+	f.file.accept()
+	return revoked, nil
+}
+
+func parseRevocationList(buf []byte) (*MemoryRevocation, error) {
+	revoked := NewMemoryRevocation()
+
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("invalid revocation entry on line %d", n)
+		}
+
+		var t time.Time
+		if len(fields) == 3 {
+			unix, err := strconv.ParseInt(fields[2], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp on line %d: %w", n, err)
+			}
+			t = time.Unix(unix, 0)
+		}
+
+		switch fields[0] {
+		case "jti":
+			revoked.RevokeToken(fields[1], t)
+		case "sub":
+			revoked.RevokeSubject(fields[1], t)
+		default:
+			return nil, fmt.Errorf("unknown revocation entry type %#v on line %d", fields[0], n)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read revocation list: %w", err)
+	}
+	return revoked, nil
+}
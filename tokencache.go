@@ -0,0 +1,144 @@
+package traefik_jwt_middleware
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/whlanuo/traefik-jwt-middleware/jwx/jwk"
+)
+
+// TokenCacheStats describes the usage of the verified token cache
+type TokenCacheStats struct {
+	Size     int
+	Capacity int
+	Hits     uint64
+	Misses   uint64
+}
+
+// HitRate returns the ratio of lookups that were served from the cache
+func (s TokenCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// tokenCache is an LRU cache of tokens whose signature was verified, keyed
+// by a hash of the compact token. Only the signature verification is cached:
+// claims such as "exp" and "nbf" must still be validated on every use.
+//
+// Entries are bound to the key set they were verified with, and the cache
+// is cleared when a different key set is used. They are dropped when the
+// token expires, or after maxAge if set.
+type tokenCache struct {
+	capacity int
+	maxAge   time.Duration
+
+	mu      sync.Mutex
+	keySet  *jwk.Set
+	entries map[[sha256.Size]byte]*list.Element
+	lru     *list.List
+	hits    uint64
+	misses  uint64
+}
+
+type tokenCacheEntry struct {
+	key   [sha256.Size]byte
+	token *VerifiedToken
+	exp   time.Time
+}
+
+func newTokenCache(capacity int, maxAge time.Duration) *tokenCache {
+	return &tokenCache{
+		capacity: capacity,
+		maxAge:   maxAge,
+		entries:  make(map[[sha256.Size]byte]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Get returns the cached token for the given compact token, if it was
+// verified with the given key set and has not expired
+func (c *tokenCache) Get(compact string, keySet *jwk.Set) (*VerifiedToken, bool) {
+	key := sha256.Sum256([]byte(compact))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.resetIfChanged(keySet)
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	entry := elem.Value.(*tokenCacheEntry)
+	if !entry.exp.IsZero() && !entry.exp.After(time.Now()) {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		c.misses++
+		return nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+	c.hits++
+	return entry.token, true
+}
+
+// Add caches the given token, verified with the given key set. If until is
+// not zero, the entry expires at that time at the latest.
+func (c *tokenCache) Add(compact string, keySet *jwk.Set, token *VerifiedToken, until time.Time) {
+	key := sha256.Sum256([]byte(compact))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.resetIfChanged(keySet)
+
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	for c.lru.Len() >= c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*tokenCacheEntry).key)
+	}
+
+	exp := token.Token.Expiration()
+	if c.maxAge > 0 {
+		if limit := time.Now().Add(c.maxAge); exp.IsZero() || exp.After(limit) {
+			exp = limit
+		}
+	}
+	if !until.IsZero() && (exp.IsZero() || exp.After(until)) {
+		exp = until
+	}
+	c.entries[key] = c.lru.PushFront(&tokenCacheEntry{key: key, token: token, exp: exp})
+}
+
+// Stats returns the current size and hit counters of the cache
+func (c *tokenCache) Stats() TokenCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return TokenCacheStats{
+		Size:     c.lru.Len(),
+		Capacity: c.capacity,
+		Hits:     c.hits,
+		Misses:   c.misses,
+	}
+}
+
+func (c *tokenCache) resetIfChanged(keySet *jwk.Set) {
+	if keySet == c.keySet {
+		return
+	}
+	c.keySet = keySet
+	c.entries = make(map[[sha256.Size]byte]*list.Element)
+	c.lru.Init()
+}
@@ -0,0 +1,74 @@
+package traefik_jwt_middleware
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// watchedFile detects changes to a local file by polling it at most once per
+// interval. A change is only remembered once it was accepted, so that a file
+// that failed to parse is read again on the next check. It is not safe for
+// concurrent use.
+type watchedFile struct {
+	path     string
+	interval time.Duration
+
+	lastCheck time.Time
+	accepted  bool
+	modTime   time.Time
+	size      int64
+	digest    [sha256.Size]byte
+
+	// pending is the state of the last read, remembered by accept
+	pending struct {
+		modTime time.Time
+		size    int64
+		digest  [sha256.Size]byte
+	}
+}
+
+// due reports whether the file should be checked again
+func (w *watchedFile) due() bool {
+	return !w.accepted || time.Since(w.lastCheck) >= w.interval
+}
+
+// read returns the contents of the file if it changed since the last
+// accepted read, or nil if it did not
+func (w *watchedFile) read() ([]byte, error) {
+	w.lastCheck = time.Now()
+
+	fi, err := os.Stat(w.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", w.path, err)
+	}
+	if w.accepted && fi.ModTime().Equal(w.modTime) && fi.Size() == w.size {
+		return nil, nil
+	}
+
+	buf, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", w.path, err)
+	}
+
+	w.pending.modTime = fi.ModTime()
+	w.pending.size = fi.Size()
+	w.pending.digest = sha256.Sum256(buf)
+	if w.accepted && w.pending.digest == w.digest {
+		// touched, but not changed
+		w.accept()
+		return nil, nil
+	}
+	return buf, nil
+}
+
+// accept remembers the state of the last read, after its contents were
+// successfully parsed
+func (w *watchedFile) accept() {
+	w.accepted = true
+	w.modTime = w.pending.modTime
+	w.size = w.pending.size
+	w.digest = w.pending.digest
+}